@@ -0,0 +1,179 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pehlicd/rbac-wizard/internal"
+)
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Run the built-in security checks against the bindings of the cluster",
+	Long: `Run the built-in catalogue of security checks against every binding of the cluster and print the findings,
+sorted by severity. The checks cover wildcard verbs and resources, cluster-admin grants to non-system subjects,
+anonymous and all-authenticated bindings, secrets readable cluster-wide, nodes/proxy, pods/exec, bindings of default
+service accounts and privilege escalation verbs. Bindings managed by Kubernetes are left out unless --include-system
+is given, except for anonymous access which is always reported.
+
+With --fail-on, the command exits with code 2 when there are findings of the given severity or higher, so that CI
+pipelines can fail builds that introduce risky RBAC. Other errors exit with code 1. Reports can be written as JSON,
+SARIF for code scanning, JUnit XML or Markdown.`,
+	Example: `  rbac-wizard audit
+  rbac-wizard audit --fail-on high -o sarif > rbac-wizard.sarif
+  rbac-wizard audit --from-dir ./manifests --fail-on critical -o junit > rbac-wizard.xml`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		includeSystem, _ := cmd.Flags().GetBool("include-system")
+		output, _ := cmd.Flags().GetString("output")
+		failOn, _ := cmd.Flags().GetString("fail-on")
+		policyDir, _ := cmd.Flags().GetString("policy-dir")
+
+		if failOn != "" && !internal.ValidSeverity(failOn) {
+			return fmt.Errorf("invalid severity %q, must be one of critical, high, medium or low", failOn)
+		}
+
+		checks := internal.Checks
+		var policies []internal.Policy
+		if policyDir != "" {
+			var err error
+			if policies, err = internal.LoadPolicies(policyDir); err != nil {
+				return err
+			}
+			for _, policy := range policies {
+				checks = append(checks, policy.Check())
+			}
+		}
+
+		apps, err := newApps(sourceFromFlags(cmd))
+		if err != nil {
+			return err
+		}
+
+		findings := []internal.Finding{}
+		for _, a := range apps {
+			bindings, err := internal.Generator(a).GetBindings()
+			if err != nil {
+				return fmt.Errorf("failed to get bindings: %w", err)
+			}
+
+			roles, err := internal.Generator(a).GetRoles()
+			if err != nil {
+				return fmt.Errorf("failed to get roles: %w", err)
+			}
+
+			for _, f := range internal.FindFindings(bindings, roles, a.Cluster, includeSystem) {
+				if a.Store != nil {
+					f.Source = a.Store.Source(f.Binding.Kind, f.Binding.Namespace, f.Binding.Name)
+				}
+				findings = append(findings, f)
+			}
+
+			violations, err := internal.EvaluatePolicies(policies, bindings, roles, a.Cluster)
+			if err != nil {
+				return err
+			}
+			findings = append(findings, violations...)
+		}
+		internal.SortFindings(findings)
+
+		if err := printFindings(findings, checks, output, len(apps) > 1); err != nil {
+			return err
+		}
+
+		if failOn == "" {
+			return nil
+		}
+		failed := 0
+		for _, f := range findings {
+			if internal.SeverityAtLeast(f.Severity, failOn) {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return &exitError{code: 2, err: fmt.Errorf("%d findings with severity %s or higher", failed, failOn)}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().Bool("include-system", false, "Include the bindings managed by Kubernetes")
+	auditCmd.Flags().StringP("output", "o", "table", "Output format [table, json, sarif, junit, markdown]")
+	auditCmd.Flags().String("policy-dir", "", "Directory of policy files with CEL rules to evaluate in addition to the built-in checks")
+	auditCmd.Flags().String("fail-on", "", "Exit with code 2 when there are findings of this severity or higher [critical, high, medium, low]")
+	addSourceFlags(auditCmd)
+}
+
+func printFindings(findings []internal.Finding, checks []internal.Check, output string, multiCluster bool) error {
+	switch output {
+	case "json":
+		return printJSON(findings)
+	case "sarif":
+		return printJSON(sarifReport(findings, checks))
+	case "junit":
+		return printJUnit(findings, checks)
+	case "markdown":
+		printFindingsMarkdown(os.Stdout, findings, multiCluster)
+		return nil
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if multiCluster {
+			_, _ = fmt.Fprint(w, "CLUSTER\t")
+		}
+		_, _ = fmt.Fprintln(w, "SEVERITY\tCHECK\tOBJECT\tROLE\tEVIDENCE")
+		for _, f := range findings {
+			if multiCluster {
+				_, _ = fmt.Fprintf(w, "%s\t", f.Cluster)
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s %s\t%s\t%s\t%s\n",
+				f.Severity, f.CheckID, f.Title, findingObject(f), findingRole(f), f.Evidence)
+		}
+		return w.Flush()
+	}
+
+	return fmt.Errorf("unsupported output format %q", output)
+}
+
+// findingObject returns the binding of a finding, or the subject of a policy violation that has no binding.
+func findingObject(f internal.Finding) string {
+	if f.Binding.Name == "" && f.Subject != nil {
+		return f.Subject.Kind + "/" + objectName(f.Subject.Namespace, f.Subject.Name)
+	}
+	return f.Binding.Kind + "/" + objectName(f.Binding.Namespace, f.Binding.Name)
+}
+
+func findingRole(f internal.Finding) string {
+	if f.RoleRef.Name == "" {
+		return "-"
+	}
+	return f.RoleRef.Kind + "/" + f.RoleRef.Name
+}
@@ -0,0 +1,265 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pehlicd/rbac-wizard/internal"
+)
+
+// sarifLevels maps the severities of findings to SARIF result levels.
+var sarifLevels = map[string]string{
+	internal.SeverityCritical: "error",
+	internal.SeverityHigh:     "error",
+	internal.SeverityMedium:   "warning",
+	internal.SeverityLow:      "note",
+}
+
+// sarifSecurityScores maps the severities of findings to the scores code scanning ranks security alerts by.
+var sarifSecurityScores = map[string]string{
+	internal.SeverityCritical: "9.5",
+	internal.SeverityHigh:     "8.0",
+	internal.SeverityMedium:   "5.5",
+	internal.SeverityLow:      "3.0",
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Version        string      `json:"version,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string            `json:"id"`
+	Name                 string            `json:"name"`
+	ShortDescription     sarifText         `json:"shortDescription"`
+	FullDescription      sarifText         `json:"fullDescription"`
+	Help                 sarifText         `json:"help"`
+	DefaultConfiguration sarifConfig       `json:"defaultConfiguration"`
+	Properties           map[string]string `json:"properties"`
+}
+
+type sarifConfig struct {
+	Level string `json:"level"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifText       `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifReport builds a SARIF 2.1.0 log of the findings, with every check as a rule.
+// Findings of bindings loaded from files are located in those files.
+func sarifReport(findings []internal.Finding, checks []internal.Check) sarifLog {
+	rules := make([]sarifRule, 0, len(checks))
+	for _, c := range checks {
+		rules = append(rules, sarifRule{
+			ID:                   c.ID,
+			Name:                 c.Title,
+			ShortDescription:     sarifText{Text: c.Title},
+			FullDescription:      sarifText{Text: c.Description},
+			Help:                 sarifText{Text: c.Remediation},
+			DefaultConfiguration: sarifConfig{Level: sarifLevels[c.Severity]},
+			Properties:           map[string]string{"security-severity": sarifSecurityScores[c.Severity]},
+		})
+	}
+
+	results := make([]sarifResult, 0, len(findings))
+	for _, f := range findings {
+		logical := sarifLogicalLocation{Name: f.Binding.Name, FullyQualifiedName: f.NodeID, Kind: f.Binding.Kind}
+		if f.Binding.Name == "" && f.Subject != nil {
+			logical.Name, logical.Kind = f.Subject.Name, f.Subject.Kind
+		}
+		location := sarifLocation{LogicalLocations: []sarifLogicalLocation{logical}}
+		if f.Source != "" {
+			location.PhysicalLocation = &sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(f.Source)},
+			}
+		}
+
+		results = append(results, sarifResult{
+			RuleID:    f.CheckID,
+			Level:     sarifLevels[f.Severity],
+			Message:   sarifText{Text: f.NodeID + ": " + f.Evidence},
+			Locations: []sarifLocation{location},
+		})
+	}
+
+	return sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "rbac-wizard",
+				InformationURI: "https://github.com/pehlicd/rbac-wizard",
+				Version:        versionString,
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// printJUnit prints the findings as JUnit XML, with one test case per check
+// that fails when the check has findings.
+func printJUnit(findings []internal.Finding, checks []internal.Check) error {
+	suite := junitTestSuite{Name: "rbac-wizard audit"}
+	for _, c := range checks {
+		testCase := junitTestCase{Name: c.ID + " " + c.Title, ClassName: "rbac-wizard." + c.ID}
+
+		var evidence []string
+		for _, f := range findings {
+			if f.CheckID == c.ID {
+				evidence = append(evidence, f.NodeID+": "+f.Evidence)
+			}
+		}
+		if len(evidence) > 0 {
+			testCase.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d %s severity findings", len(evidence), c.Severity),
+				Type:    c.Severity,
+				Text:    strings.Join(evidence, "\n") + "\n\nRemediation: " + c.Remediation,
+			}
+			suite.Failures++
+		}
+
+		suite.Cases = append(suite.Cases, testCase)
+		suite.Tests++
+	}
+
+	_, _ = fmt.Fprint(os.Stdout, xml.Header)
+	encoder := xml.NewEncoder(os.Stdout)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(os.Stdout)
+	return nil
+}
+
+func printFindingsMarkdown(w io.Writer, findings []internal.Finding, multiCluster bool) {
+	_, _ = fmt.Fprintln(w, "# RBAC audit")
+	_, _ = fmt.Fprintln(w)
+	if len(findings) == 0 {
+		_, _ = fmt.Fprintln(w, "No findings.")
+		return
+	}
+
+	counts := map[string]int{}
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+	_, _ = fmt.Fprintln(w, "| Severity | Findings |")
+	_, _ = fmt.Fprintln(w, "|----------|----------|")
+	for _, severity := range []string{internal.SeverityCritical, internal.SeverityHigh, internal.SeverityMedium, internal.SeverityLow} {
+		_, _ = fmt.Fprintf(w, "| %s | %d |\n", severity, counts[severity])
+	}
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintln(w, "## Findings")
+	_, _ = fmt.Fprintln(w)
+	if multiCluster {
+		_, _ = fmt.Fprintln(w, "| Cluster | Severity | Check | Object | Role | Evidence |")
+		_, _ = fmt.Fprintln(w, "|---------|----------|-------|---------|------|----------|")
+	} else {
+		_, _ = fmt.Fprintln(w, "| Severity | Check | Object | Role | Evidence |")
+		_, _ = fmt.Fprintln(w, "|----------|-------|---------|------|----------|")
+	}
+	for _, f := range findings {
+		if multiCluster {
+			_, _ = fmt.Fprintf(w, "| %s ", f.Cluster)
+		}
+		_, _ = fmt.Fprintf(w, "| %s | %s %s | `%s` | `%s` | %s |\n", f.Severity, f.CheckID, markdownEscape(f.Title),
+			findingObject(f), findingRole(f), markdownEscape(f.Evidence))
+	}
+}
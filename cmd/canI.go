@@ -0,0 +1,157 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/rbac/v1"
+
+	"github.com/pehlicd/rbac-wizard/internal"
+)
+
+// canICmd represents the can-i command
+var canICmd = &cobra.Command{
+	Use:   "can-i VERB RESOURCE [NAME] | can-i VERB URL",
+	Short: "Check whether a user can perform an action",
+	Long: `Check whether a user can perform an action, deciding like the RBAC authorizer of the API server does over the
+bindings and roles that are read. Unlike "kubectl auth can-i" it works on files and snapshots, and explains which
+binding and rule allowed the request. The resource can be given in the "resource[.group][/subresource]" form, the API
+group of built-in resources is found when it is omitted. Non-resource URLs start with a "/". The user is given with
+--as, service accounts as "system:serviceaccount:NAMESPACE:NAME", and gets the groups Kubernetes adds to it, the groups
+given with --as-group and its groups in identity files. Without --namespace the request is cluster scoped. Exits with
+1 when the request is not allowed.`,
+	Example: `  rbac-wizard can-i --as jane list pods -n payments
+  rbac-wizard can-i --as system:serviceaccount:ci:deployer update deployments.apps/scale -n payments
+  rbac-wizard can-i --as jane --as-group developers get secrets db-password -n payments --snapshot cluster.tar.gz
+  rbac-wizard can-i --as jane get /healthz`,
+	Args: cobra.RangeArgs(2, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user, _ := cmd.Flags().GetString("as")
+		groups, _ := cmd.Flags().GetStringArray("as-group")
+		namespace, _ := cmd.Flags().GetString("namespace")
+		subresource, _ := cmd.Flags().GetString("subresource")
+		output, _ := cmd.Flags().GetString("output")
+
+		request := internal.Request{ResourceAttributes: internal.ResourceAttributes{Verb: args[0]}}
+		if strings.HasPrefix(args[1], "/") {
+			if len(args) > 2 {
+				return errors.New("non-resource URLs have no name")
+			}
+			request.Path = args[1]
+		} else {
+			resource, group, sub := internal.ParseResource(args[1])
+			if group == v1.APIGroupAll && resource != v1.ResourceAll {
+				var ok bool
+				if group, ok = internal.APIGroupOf(resource); !ok {
+					return fmt.Errorf("unknown resource %q, give its API group in the resource.group form", resource)
+				}
+			}
+			if subresource == "" {
+				subresource = sub
+			}
+			request.APIGroup = group
+			request.Resource = resource
+			request.Subresource = subresource
+			request.Namespace = namespace
+			if len(args) > 2 {
+				request.Name = args[2]
+			}
+		}
+
+		a, err := newApp(sourceFromFlags(cmd))
+		if err != nil {
+			return err
+		}
+
+		decision, err := authorize(a, user, groups, request)
+		if err != nil {
+			return err
+		}
+
+		if err := printDecision(decision, output); err != nil {
+			return err
+		}
+		if !decision.Allowed {
+			return &exitError{code: 1, err: errors.New("the request is not allowed")}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(canICmd)
+
+	canICmd.Flags().String("as", "", "User to check the request for, service accounts as system:serviceaccount:NAMESPACE:NAME")
+	canICmd.Flags().StringArray("as-group", nil, "Group of the user, can be repeated")
+	canICmd.Flags().StringP("namespace", "n", "", "Namespace of the request, cluster scoped when empty")
+	canICmd.Flags().String("subresource", "", "Subresource of the request")
+	canICmd.Flags().StringP("output", "o", "text", "Output format [text, json]")
+	addSourceFlags(canICmd)
+	_ = canICmd.MarkFlagRequired("as")
+}
+
+// authorize decides on the request of the user with the bindings and roles of the cluster of the app.
+func authorize(a internal.App, user string, groups []string, request internal.Request) (internal.Decision, error) {
+	bindings, err := internal.Generator(a).GetBindings()
+	if err != nil {
+		return internal.Decision{}, fmt.Errorf("failed to get bindings: %w", err)
+	}
+
+	roles, err := internal.Generator(a).GetRoles()
+	if err != nil {
+		return internal.Decision{}, fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	identities, err := a.GetIdentities()
+	if err != nil {
+		return internal.Decision{}, fmt.Errorf("failed to get identities: %w", err)
+	}
+
+	request.User = internal.IdentityForUser(user, append(groups, identities.Groups(user)...))
+
+	return internal.Authorize(bindings, roles, request), nil
+}
+
+func printDecision(decision internal.Decision, output string) error {
+	switch output {
+	case "json":
+		return printJSON(decision)
+	case "text":
+		if !decision.Allowed {
+			fmt.Printf("no\n%s\n", decision.Reason)
+			return nil
+		}
+		fmt.Printf("yes\n%s\n", decision.Reason)
+		fmt.Printf("Rule: %s\n", internal.FormatRule(*decision.Rule))
+		if decision.AggregatedFrom != "" {
+			fmt.Printf("Aggregated from: ClusterRole %s\n", decision.AggregatedFrom)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unsupported output format %q", output)
+}
@@ -0,0 +1,236 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/rbac/v1"
+
+	"github.com/pehlicd/rbac-wizard/internal"
+)
+
+// liveSource is the argument of the diff command that refers to the live cluster.
+const liveSource = "live"
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff OLD NEW",
+	Short: "Show the RBAC changes between two points in time",
+	Long: `Show the RBAC changes between two points in time. OLD and NEW can be snapshots taken with 'rbac-wizard snapshot save',
+files or directories of manifests, or "live" for the cluster the current kubeconfig points to. The diff lists added,
+removed and modified bindings and roles, and the changes of the effective permissions of every subject.`,
+	Example: `  rbac-wizard diff before.tar.gz after.tar.gz
+  rbac-wizard diff last-release.tar.gz live -o markdown`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+
+		old, err := loadStore(args[0])
+		if err != nil {
+			return err
+		}
+
+		current, err := loadStore(args[1])
+		if err != nil {
+			return err
+		}
+
+		diff := internal.DiffStores(old, current)
+
+		switch output {
+		case "json":
+			return printJSON(diff)
+		case "text":
+			printDiffText(os.Stdout, diff)
+		case "markdown":
+			printDiffMarkdown(os.Stdout, diff)
+		default:
+			return fmt.Errorf("unsupported output format %q", output)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringP("output", "o", "text", "Output format [text, json, markdown]")
+}
+
+// loadStore reads the objects of a snapshot, of manifest files or of the live cluster into a store.
+func loadStore(ref string) (*internal.Store, error) {
+	var src source
+	switch {
+	case ref == liveSource:
+	case strings.HasSuffix(ref, ".tar.gz"), strings.HasSuffix(ref, ".tgz"):
+		src.Snapshot = ref
+	default:
+		src.Paths = []string{ref}
+	}
+
+	a, err := newApp(src)
+	if err != nil {
+		return nil, err
+	}
+	if a.Store != nil {
+		return a.Store, nil
+	}
+
+	snapshot, err := a.TakeSnapshot(internal.SnapshotMetadata{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the live cluster: %w", err)
+	}
+	return snapshot.Store, nil
+}
+
+func printDiffText(w io.Writer, diff *internal.Diff) {
+	if diff.Empty() {
+		_, _ = fmt.Fprintln(w, "No changes")
+		return
+	}
+
+	for _, section := range []struct {
+		title   string
+		changes []internal.ObjectChange
+	}{{"Bindings", diff.Bindings}, {"Roles", diff.Roles}} {
+		if len(section.changes) == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s:\n", section.title)
+		for _, c := range section.changes {
+			_, _ = fmt.Fprintf(w, "  %s %s %s\n", changeSymbol(c.Type), c.Kind, objectName(c.Namespace, c.Name))
+			for _, line := range objectChangeDetails(c) {
+				_, _ = fmt.Fprintf(w, "      %s\n", line)
+			}
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if len(diff.Permissions) > 0 {
+		_, _ = fmt.Fprintln(w, "Effective permissions:")
+		for _, c := range diff.Permissions {
+			_, _ = fmt.Fprintf(w, "  %s\n", subjectName(c.Subject))
+			for _, p := range c.Added {
+				_, _ = fmt.Fprintf(w, "      + %s\n", p)
+			}
+			for _, p := range c.Removed {
+				_, _ = fmt.Fprintf(w, "      - %s\n", p)
+			}
+		}
+	}
+}
+
+func printDiffMarkdown(w io.Writer, diff *internal.Diff) {
+	_, _ = fmt.Fprintln(w, "# RBAC diff")
+	_, _ = fmt.Fprintln(w)
+	if diff.Empty() {
+		_, _ = fmt.Fprintln(w, "No changes.")
+		return
+	}
+
+	for _, section := range []struct {
+		title   string
+		changes []internal.ObjectChange
+	}{{"Bindings", diff.Bindings}, {"Roles", diff.Roles}} {
+		if len(section.changes) == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "## %s\n\n", section.title)
+		_, _ = fmt.Fprintln(w, "| Change | Kind | Name | Details |")
+		_, _ = fmt.Fprintln(w, "|--------|------|------|---------|")
+		for _, c := range section.changes {
+			_, _ = fmt.Fprintf(w, "| %s | %s | `%s` | %s |\n", c.Type, c.Kind, objectName(c.Namespace, c.Name),
+				markdownEscape(strings.Join(objectChangeDetails(c), "<br>")))
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if len(diff.Permissions) > 0 {
+		_, _ = fmt.Fprintln(w, "## Effective permissions")
+		_, _ = fmt.Fprintln(w)
+		_, _ = fmt.Fprintln(w, "| Subject | Change | Permission |")
+		_, _ = fmt.Fprintln(w, "|---------|--------|------------|")
+		for _, c := range diff.Permissions {
+			for _, p := range c.Added {
+				_, _ = fmt.Fprintf(w, "| `%s` | added | `%s` |\n", subjectName(c.Subject), p)
+			}
+			for _, p := range c.Removed {
+				_, _ = fmt.Fprintf(w, "| `%s` | removed | `%s` |\n", subjectName(c.Subject), p)
+			}
+		}
+	}
+}
+
+func objectChangeDetails(c internal.ObjectChange) []string {
+	var lines []string
+	if c.OldRoleRef != nil && c.NewRoleRef != nil {
+		lines = append(lines, fmt.Sprintf("~ roleRef %s/%s -> %s/%s", c.OldRoleRef.Kind, c.OldRoleRef.Name, c.NewRoleRef.Kind, c.NewRoleRef.Name))
+	} else if c.NewRoleRef != nil {
+		lines = append(lines, fmt.Sprintf("+ roleRef %s/%s", c.NewRoleRef.Kind, c.NewRoleRef.Name))
+	} else if c.OldRoleRef != nil {
+		lines = append(lines, fmt.Sprintf("- roleRef %s/%s", c.OldRoleRef.Kind, c.OldRoleRef.Name))
+	}
+	for _, s := range c.SubjectsAdded {
+		lines = append(lines, "+ subject "+subjectName(s))
+	}
+	for _, s := range c.SubjectsRemoved {
+		lines = append(lines, "- subject "+subjectName(s))
+	}
+	for _, r := range c.RulesAdded {
+		lines = append(lines, "+ rule "+internal.FormatRule(r))
+	}
+	for _, r := range c.RulesRemoved {
+		lines = append(lines, "- rule "+internal.FormatRule(r))
+	}
+	return lines
+}
+
+func changeSymbol(changeType string) string {
+	switch changeType {
+	case internal.ChangeAdded:
+		return "+"
+	case internal.ChangeRemoved:
+		return "-"
+	}
+	return "~"
+}
+
+func objectName(namespace string, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+func subjectName(subject v1.Subject) string {
+	return subject.Kind + " " + objectName(subject.Namespace, subject.Name)
+}
+
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
@@ -0,0 +1,138 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pehlicd/rbac-wizard/internal"
+)
+
+// escalationsCmd represents the escalations command
+var escalationsCmd = &cobra.Command{
+	Use:   "escalations",
+	Short: "Show the privilege escalation paths of the cluster",
+	Long: `Show the subjects that can gain permissions they were not granted. A subject escalates directly when it can use
+the escalate or bind verbs on roles, impersonate any user or group, patch bindings, approve certificate signing requests
+or write admission webhook configurations. Escalation paths also go through the subjects a subject can act as, by
+creating pods or tokens for their service accounts or by impersonating them, up to a subject that can escalate or is a
+cluster admin. Paths that start from system subjects are left out unless --include-system is given.`,
+	Example: `  rbac-wizard escalations
+  rbac-wizard escalations --include-system -o json
+  rbac-wizard escalations --from-dir ./manifests`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		includeSystem, _ := cmd.Flags().GetBool("include-system")
+		output, _ := cmd.Flags().GetString("output")
+
+		apps, err := newApps(sourceFromFlags(cmd))
+		if err != nil {
+			return err
+		}
+
+		results := []internal.Escalation{}
+		for _, a := range apps {
+			escalations, err := findEscalations(a, includeSystem)
+			if err != nil {
+				return err
+			}
+			results = append(results, escalations...)
+		}
+
+		return printEscalations(results, output, len(apps) > 1)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(escalationsCmd)
+
+	escalationsCmd.Flags().Bool("include-system", false, "Include escalation paths that start from system subjects")
+	escalationsCmd.Flags().StringP("output", "o", "table", "Output format [table, json]")
+	addSourceFlags(escalationsCmd)
+}
+
+// findEscalations finds the escalation paths of the cluster of the app.
+func findEscalations(a internal.App, includeSystem bool) ([]internal.Escalation, error) {
+	bindings, err := internal.Generator(a).GetBindings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bindings: %w", err)
+	}
+
+	roles, err := internal.Generator(a).GetRoles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	escalations := internal.FindEscalations(bindings, roles, includeSystem)
+	for i := range escalations {
+		escalations[i].Cluster = a.Cluster
+	}
+
+	return escalations, nil
+}
+
+func printEscalations(escalations []internal.Escalation, output string, multiCluster bool) error {
+	switch output {
+	case "json":
+		return printJSON(escalations)
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if multiCluster {
+			_, _ = fmt.Fprint(w, "CLUSTER\t")
+		}
+		_, _ = fmt.Fprintln(w, "SUBJECT\tTECHNIQUE\tSCOPE\tPATH\tBINDING")
+		for _, e := range escalations {
+			if multiCluster {
+				_, _ = fmt.Fprintf(w, "%s\t", e.Cluster)
+			}
+			technique := e.Technique
+			if len(e.ResourceNames) > 0 {
+				technique += " (" + strings.Join(e.ResourceNames, ", ") + ")"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s/%s\n",
+				subjectName(e.Subject), technique, orDash(e.Namespace, "cluster"), formatPath(e.Path),
+				e.Binding.Kind, objectName(e.Binding.Namespace, e.Binding.Name))
+		}
+		return w.Flush()
+	}
+
+	return fmt.Errorf("unsupported output format %q", output)
+}
+
+// formatPath renders the subjects of an escalation path with the technique used to act as each of them.
+func formatPath(path []internal.EscalationHop) string {
+	if len(path) == 0 {
+		return "-"
+	}
+
+	hops := make([]string, 0, len(path))
+	for _, hop := range path {
+		hops = append(hops, hop.Technique+" as "+subjectName(hop.Subject))
+	}
+	return strings.Join(hops, " -> ")
+}
@@ -0,0 +1,131 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pehlicd/rbac-wizard/internal"
+)
+
+// hygieneCmd represents the hygiene command
+var hygieneCmd = &cobra.Command{
+	Use:   "hygiene",
+	Short: "Show the unused and dangling RBAC objects of the cluster",
+	Long: `Show the RBAC objects that can be cleaned up: ClusterRoles and Roles that no binding references, bindings whose
+role does not exist, bindings of service accounts that do not exist and roles and bindings left in namespaces that do
+not exist or are being deleted. ClusterRoles aggregated into another ClusterRole count as referenced. Objects managed by
+Kubernetes are left out unless --include-system is given.`,
+	Example: `  rbac-wizard hygiene
+  rbac-wizard hygiene -o json
+  rbac-wizard hygiene --from-dir ./manifests`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		includeSystem, _ := cmd.Flags().GetBool("include-system")
+		output, _ := cmd.Flags().GetString("output")
+
+		apps, err := newApps(sourceFromFlags(cmd))
+		if err != nil {
+			return err
+		}
+
+		results := []internal.HygieneIssue{}
+		for _, a := range apps {
+			issues, err := findHygieneIssues(a, includeSystem)
+			if err != nil {
+				return err
+			}
+			results = append(results, issues...)
+		}
+
+		return printHygieneIssues(results, output, len(apps) > 1)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(hygieneCmd)
+
+	hygieneCmd.Flags().Bool("include-system", false, "Include the roles and bindings managed by Kubernetes")
+	hygieneCmd.Flags().StringP("output", "o", "table", "Output format [table, json]")
+	addSourceFlags(hygieneCmd)
+}
+
+// findHygieneIssues finds the unused and dangling RBAC objects of the cluster of the app.
+func findHygieneIssues(a internal.App, includeSystem bool) ([]internal.HygieneIssue, error) {
+	bindings, err := internal.Generator(a).GetBindings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bindings: %w", err)
+	}
+
+	roles, err := internal.Generator(a).GetRoles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	serviceAccounts, err := a.GetServiceAccounts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service accounts: %w", err)
+	}
+
+	namespaces, err := a.GetNamespaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get namespaces: %w", err)
+	}
+
+	issues := internal.FindHygieneIssues(bindings, roles, serviceAccounts, namespaces, includeSystem)
+	for i := range issues {
+		issues[i].Cluster = a.Cluster
+		if a.Store != nil {
+			issues[i].Source = a.Store.Source(issues[i].Kind, issues[i].Namespace, issues[i].Name)
+		}
+	}
+
+	return issues, nil
+}
+
+func printHygieneIssues(issues []internal.HygieneIssue, output string, multiCluster bool) error {
+	switch output {
+	case "json":
+		return printJSON(issues)
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if multiCluster {
+			_, _ = fmt.Fprint(w, "CLUSTER\t")
+		}
+		_, _ = fmt.Fprintln(w, "ISSUE\tOBJECT\tDESCRIPTION")
+		for _, issue := range issues {
+			if multiCluster {
+				_, _ = fmt.Fprintf(w, "%s\t", issue.Cluster)
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s/%s\t%s\n",
+				issue.Type, issue.Kind, objectName(issue.Namespace, issue.Name), issue.Description)
+		}
+		return w.Flush()
+	}
+
+	return fmt.Errorf("unsupported output format %q", output)
+}
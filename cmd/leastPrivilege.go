@@ -0,0 +1,157 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/rbac/v1"
+
+	"github.com/pehlicd/rbac-wizard/internal"
+)
+
+// leastPrivilegeCmd represents the least-privilege command
+var leastPrivilegeCmd = &cobra.Command{
+	Use:   "least-privilege KIND NAME",
+	Short: "Generate the minimal roles of a subject from audit logs",
+	Long: `Generate the minimal Roles, ClusterRoles and bindings that grant a User, Group or ServiceAccount exactly the
+requests it made according to the JSON lines audit logs of the API server. Requests in a namespace are granted by a Role
+in that namespace, requests on cluster scoped resources, on resources of all namespaces and on non-resource URLs by a
+ClusterRole. Denied requests are left out. The manifests are printed as YAML, ready to review and apply, and can be
+previewed on the what-if page of the server. With -o diff the permissions the generated roles add and the current
+permissions they leave out are printed instead.`,
+	Example: `  rbac-wizard least-privilege ServiceAccount my-app -n my-namespace --audit-log audit.log
+  rbac-wizard least-privilege User jane --audit-log ./audit-logs --since 168h -o diff
+  rbac-wizard least-privilege Group developers --audit-log audit.log --name developers > developers-rbac.yaml`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespace, _ := cmd.Flags().GetString("namespace")
+		auditLogs, _ := cmd.Flags().GetStringArray("audit-log")
+		name, _ := cmd.Flags().GetString("name")
+		output, _ := cmd.Flags().GetString("output")
+
+		subject, err := internal.NewSubject(args[0], namespace, args[1])
+		if err != nil {
+			return err
+		}
+
+		filter, err := auditFilterFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		events, err := internal.LoadAuditEvents(auditLogs, filter)
+		if err != nil {
+			return fmt.Errorf("failed to read audit logs: %w", err)
+		}
+
+		a, err := newApp(sourceFromFlags(cmd))
+		if err != nil {
+			return err
+		}
+
+		lp, err := generateLeastPrivilege(a, events, subject, name)
+		if err != nil {
+			return err
+		}
+
+		return printLeastPrivilege(lp, output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(leastPrivilegeCmd)
+
+	leastPrivilegeCmd.Flags().StringP("namespace", "n", "", "Namespace of the service account")
+	leastPrivilegeCmd.Flags().StringArray("audit-log", nil, "JSON lines audit log file or directory of audit logs, can be repeated")
+	leastPrivilegeCmd.Flags().String("name", "", "Name of the generated roles and bindings, derived from the subject when empty")
+	leastPrivilegeCmd.Flags().StringP("output", "o", "yaml", "Output format [yaml, diff, json]")
+	addAuditFilterFlags(leastPrivilegeCmd)
+	addSourceFlags(leastPrivilegeCmd)
+	_ = leastPrivilegeCmd.MarkFlagRequired("audit-log")
+}
+
+// generateLeastPrivilege generates the minimal roles of the subject from the audit events and compares
+// them with the permissions the subject is granted in the cluster of the app.
+func generateLeastPrivilege(a internal.App, events []internal.AuditEvent, subject v1.Subject, name string) (*internal.LeastPrivilege, error) {
+	bindings, err := internal.Generator(a).GetBindings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bindings: %w", err)
+	}
+
+	roles, err := internal.Generator(a).GetRoles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	identities, err := a.GetIdentities()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identities: %w", err)
+	}
+
+	if name == "" {
+		name = leastPrivilegeName(subject)
+	}
+
+	lp := internal.GenerateLeastPrivilege(events, subject, name)
+	lp.Compare(bindings, roles, identities.IdentityFor(subject))
+
+	return lp, nil
+}
+
+// leastPrivilegeName returns the default name of the generated roles and bindings of a subject.
+func leastPrivilegeName(subject v1.Subject) string {
+	if subject.Kind == v1.ServiceAccountKind {
+		return "rbac-wizard:" + subject.Namespace + ":" + subject.Name
+	}
+	return "rbac-wizard:" + subject.Name
+}
+
+func printLeastPrivilege(lp *internal.LeastPrivilege, output string) error {
+	switch output {
+	case "json":
+		return printJSON(lp)
+	case "yaml":
+		if lp.Requests == 0 {
+			return fmt.Errorf("no allowed requests of %s found in the audit logs", subjectName(lp.Subject))
+		}
+		content, err := lp.YAML()
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(content)
+		return err
+	case "diff":
+		fmt.Printf("Generated from %d requests\n\n", lp.Requests)
+		diff := &internal.Diff{}
+		if len(lp.Changes.Added) > 0 || len(lp.Changes.Removed) > 0 {
+			diff.Permissions = []internal.PermissionChange{lp.Changes}
+		}
+		printDiffText(os.Stdout, diff)
+		return nil
+	}
+
+	return fmt.Errorf("unsupported output format %q", output)
+}
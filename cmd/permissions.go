@@ -0,0 +1,160 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/rbac/v1"
+
+	"github.com/pehlicd/rbac-wizard/internal"
+)
+
+// permissionsCmd represents the permissions command
+var permissionsCmd = &cobra.Command{
+	Use:   "permissions KIND NAME",
+	Short: "Show the effective permissions of a subject",
+	Long: `Show the effective permissions of a User, Group or ServiceAccount. This will walk every ClusterRoleBinding and
+RoleBinding in the cluster, resolve the roles they reference and print the merged set of permissions of the subject.`,
+	Example: `  rbac-wizard permissions ServiceAccount default -n kube-system
+  rbac-wizard permissions ServiceAccount my-app -n my-namespace --all-contexts
+  rbac-wizard permissions User jane -o json
+  rbac-wizard permissions User alice --identity-file groups.yaml`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespace, _ := cmd.Flags().GetString("namespace")
+		output, _ := cmd.Flags().GetString("output")
+
+		subject, err := internal.NewSubject(args[0], namespace, args[1])
+		if err != nil {
+			return err
+		}
+
+		apps, err := newApps(sourceFromFlags(cmd))
+		if err != nil {
+			return err
+		}
+
+		results := make([]internal.SubjectPermissions, 0, len(apps))
+		for _, a := range apps {
+			permissions, err := resolvePermissions(a, subject)
+			if err != nil {
+				return err
+			}
+			results = append(results, permissions)
+		}
+
+		return printPermissions(results, output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(permissionsCmd)
+
+	permissionsCmd.Flags().StringP("namespace", "n", "", "Namespace of the service account")
+	permissionsCmd.Flags().StringP("output", "o", "table", "Output format [table, json]")
+	addSourceFlags(permissionsCmd)
+}
+
+// resolvePermissions resolves the effective permissions of the subject in the cluster of the app.
+func resolvePermissions(a internal.App, subject v1.Subject) (internal.SubjectPermissions, error) {
+	bindings, err := internal.Generator(a).GetBindings()
+	if err != nil {
+		return internal.SubjectPermissions{}, fmt.Errorf("failed to get bindings: %w", err)
+	}
+
+	roles, err := internal.Generator(a).GetRoles()
+	if err != nil {
+		return internal.SubjectPermissions{}, fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	identities, err := a.GetIdentities()
+	if err != nil {
+		return internal.SubjectPermissions{}, fmt.Errorf("failed to get identities: %w", err)
+	}
+
+	permissions := internal.ResolveIdentityPermissions(bindings, roles, subject, identities.IdentityFor(subject))
+	permissions.Cluster = a.Cluster
+	if subject.Kind == v1.UserKind {
+		permissions.Groups = identities.Groups(subject.Name)
+	}
+
+	return permissions, nil
+}
+
+// printPermissions prints the permissions of a subject, with a cluster column when they come from several clusters.
+func printPermissions(results []internal.SubjectPermissions, output string) error {
+	switch output {
+	case "json":
+		if len(results) == 1 {
+			return printJSON(results[0])
+		}
+		return printJSON(results)
+	case "table":
+		multiCluster := len(results) > 1
+		if !multiCluster && len(results[0].Groups) > 0 {
+			fmt.Printf("Groups: %s\n\n", strings.Join(results[0].Groups, ", "))
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if multiCluster {
+			_, _ = fmt.Fprint(w, "CLUSTER\t")
+		}
+		_, _ = fmt.Fprintln(w, "NAMESPACE\tAPI GROUP\tRESOURCE\tRESOURCE NAME\tNON-RESOURCE URL\tVERB")
+		for _, permissions := range results {
+			for _, p := range permissions.Permissions {
+				resource := p.Resource
+				if p.Subresource != "" {
+					resource += "/" + p.Subresource
+				}
+				if multiCluster {
+					_, _ = fmt.Fprintf(w, "%s\t", permissions.Cluster)
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+					orDash(p.Namespace, "*"), orDash(p.APIGroup, "core"), orDash(resource, "-"),
+					orDash(p.ResourceName, "-"), orDash(p.NonResourceURL, "-"), p.Verb)
+			}
+		}
+		return w.Flush()
+	}
+
+	return fmt.Errorf("unsupported output format %q", output)
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// orDash returns the fallback when the value is empty.
+func orDash(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -23,6 +23,7 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -33,10 +34,26 @@ var rootCmd = &cobra.Command{
 	Use:   "rbac-wizard",
 	Short: "RBAC Wizard is a tool to visualize Kubernetes RBAC resources.",
 	Long:  `RBAC Wizard is a tool to visualize Kubernetes RBAC resources. It can be used to view and check the permissions relationships between RBAC objects.`,
+	// Do not print the usage when a command fails after its arguments were parsed
+	SilenceUsage: true,
+}
+
+// exitError is an error that exits rbac-wizard with a specific code instead of 1.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
 }
 
 func Execute() {
 	err := rootCmd.Execute()
+	var exit *exitError
+	if errors.As(err, &exit) {
+		os.Exit(exit.code)
+	}
 	if err != nil {
 		os.Exit(1)
 	}
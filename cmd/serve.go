@@ -23,19 +23,18 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/rakyll/statik/fs"
 	"github.com/rs/cors"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v2"
 	v1 "k8s.io/api/rbac/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime"
-	kyaml "k8s.io/apimachinery/pkg/runtime/serializer/yaml"
 
 	"github.com/pehlicd/rbac-wizard/internal"
 	"github.com/pehlicd/rbac-wizard/internal/logger"
@@ -52,14 +51,25 @@ var serveCmd = &cobra.Command{
 		enableLogging, _ := cmd.Flags().GetBool("logging")
 		logLevel, _ := cmd.Flags().GetString("log-level")
 		logFormat, _ := cmd.Flags().GetString("log-format")
-		serve(port, enableLogging, logLevel, logFormat)
+		auditLogs, _ := cmd.Flags().GetStringArray("audit-log")
+		serve(port, enableLogging, logLevel, logFormat, sourceFromFlags(cmd), auditLogs)
 	},
 }
 
 var app internal.App
 
+// eventsInterval is the interval binding changes are coalesced in before they are sent to clients
+const eventsInterval = 500 * time.Millisecond
+
 type Serve struct {
+	// App is the app of the default cluster
 	App internal.App
+	// Clusters holds one app per served cluster
+	Clusters []internal.App
+	// Broadcasters holds the binding changes broadcaster of every cluster
+	Broadcasters map[string]*internal.Broadcaster
+	// AuditEvents are the events of the audit logs the usage of permissions is computed from
+	AuditEvents []internal.AuditEvent
 }
 
 func init() {
@@ -69,9 +79,11 @@ func init() {
 	serveCmd.Flags().BoolP("logging", "g", false, "Enable logging")
 	serveCmd.Flags().StringP("log-level", "l", "info", "Log level")
 	serveCmd.Flags().StringP("log-format", "f", "text", "Log format default is text [text, json]")
+	serveCmd.Flags().StringArray("audit-log", nil, "JSON lines audit log file or directory of audit logs to show the usage of permissions from, can be repeated")
+	addSourceFlags(serveCmd)
 }
 
-func serve(port string, logging bool, logLevel string, logFormat string) {
+func serve(port string, logging bool, logLevel string, logFormat string, src source, auditLogs []string) {
 	// Set up logger if logging is enabled
 	if logging {
 		l := logger.New(logLevel, logFormat)
@@ -81,15 +93,34 @@ func serve(port string, logging bool, logLevel string, logFormat string) {
 		app.Logger = l
 	}
 
-	kubeClient, err := internal.GetClientset()
+	clusters, err := connectAll(app.Logger, src)
 	if err != nil {
 		app.Logger.Fatal().Err(err).Msg("Failed to create Kubernetes client")
 	}
 
-	app.KubeClient = kubeClient
+	// Serve live clusters from informer caches instead of listing them on every request
+	for i := range clusters {
+		if clusters[i].Store == nil {
+			clusters[i].StartInformers(context.Background())
+		}
+	}
+	app = clusters[0]
 
 	serve := Serve{
-		app,
+		App:          app,
+		Clusters:     clusters,
+		Broadcasters: map[string]*internal.Broadcaster{},
+	}
+	for _, a := range clusters {
+		serve.Broadcasters[a.Cluster] = internal.NewBroadcaster(context.Background(), a.Store, a.Cluster, eventsInterval)
+	}
+
+	if len(auditLogs) > 0 {
+		serve.AuditEvents, err = internal.LoadAuditEvents(auditLogs, internal.AuditFilter{})
+		if err != nil {
+			app.Logger.Fatal().Err(err).Msg("Failed to read audit logs")
+		}
+		app.Logger.Info().Int("events", len(serve.AuditEvents)).Msg("Loaded audit logs")
 	}
 
 	// Set up statik filesystem
@@ -111,8 +142,22 @@ func serve(port string, logging bool, logLevel string, logFormat string) {
 	mux.HandleFunc("/what-if", func(w http.ResponseWriter, r *http.Request) {
 		serveStaticFiles(statikFS, w, r, "what-if.html")
 	})
+	mux.HandleFunc("/readyz", serve.readyHandler)
 	mux.HandleFunc("/api/data", serve.dataHandler)
+	mux.HandleFunc("GET /api/roles", serve.rolesHandler)
+	mux.HandleFunc("GET /api/workloads", serve.workloadsHandler)
 	mux.HandleFunc("/api/what-if", serve.whatIfHandler)
+	// Users and Groups are not namespaced, their subject routes take the "-" placeholder as namespace
+	mux.HandleFunc("GET /api/subjects/{kind}/{namespace}/{name}/permissions", serve.permissionsHandler)
+	mux.HandleFunc("GET /api/who-can", serve.whoCanHandler)
+	mux.HandleFunc("GET /api/escalations", serve.escalationsHandler)
+	mux.HandleFunc("GET /api/hygiene", serve.hygieneHandler)
+	mux.HandleFunc("GET /api/usage", serve.usageHandler)
+	mux.HandleFunc("POST /api/diff", serve.diffHandler)
+	mux.HandleFunc("GET /api/clusters", serve.clustersHandler)
+	mux.HandleFunc("GET /api/events", serve.eventsHandler)
+	mux.HandleFunc("GET /api/subjects/{kind}/{namespace}/{name}/clusters", serve.subjectClustersHandler)
+	mux.HandleFunc("GET /api/subjects/{kind}/{namespace}/{name}/least-privilege", serve.leastPrivilegeHandler)
 
 	handler := c.Handler(serve.App.LoggerMiddleware(mux))
 
@@ -173,19 +218,90 @@ func serveStaticFiles(statikFS http.FileSystem, w http.ResponseWriter, r *http.R
 	http.ServeContent(w, r, path, fileInfo.ModTime(), file)
 }
 
-func (s *Serve) dataHandler(w http.ResponseWriter, _ *http.Request) {
+// appFor returns the app of the cluster selected with the cluster query parameter,
+// or the app of the default cluster when none is selected.
+func (s *Serve) appFor(w http.ResponseWriter, r *http.Request) (internal.App, bool) {
+	cluster := r.URL.Query().Get("cluster")
+	if cluster == "" {
+		return s.App, s.checkReady(w, s.App)
+	}
+
+	for _, a := range s.Clusters {
+		if a.Cluster == cluster {
+			return a, s.checkReady(w, a)
+		}
+	}
+
+	s.App.Logger.Error().Str("cluster", cluster).Msg("Unknown cluster")
+	http.Error(w, "Unknown cluster", http.StatusNotFound)
+	return internal.App{}, false
+}
+
+// checkReady responds with an error if the app cannot serve requests yet.
+func (s *Serve) checkReady(w http.ResponseWriter, a internal.App) bool {
+	if a.Ready() {
+		return true
+	}
+
+	s.App.Logger.Warn().Str("cluster", a.Cluster).Msg("Cache is not synced yet")
+	http.Error(w, "Cache is not synced yet", http.StatusServiceUnavailable)
+	return false
+}
+
+// readyHandler reports whether the caches of every cluster have synced.
+func (s *Serve) readyHandler(w http.ResponseWriter, _ *http.Request) {
+	cacheControllers(w)
+
+	for _, a := range s.Clusters {
+		if !s.checkReady(w, a) {
+			return
+		}
+	}
+
+	_, _ = w.Write([]byte("ok"))
+}
+
+// dataHandler serves the bindings of the selected cluster, or of every cluster when none is selected.
+func (s *Serve) dataHandler(w http.ResponseWriter, r *http.Request) {
 	// Set cache control headers
 	cacheControllers(w)
 
-	// Get the bindings
-	bindings, err := internal.Generator(app).GetBindings()
-	if err != nil {
-		s.App.Logger.Error().Err(err).Msg("Failed to get bindings")
-		http.Error(w, "Failed to get bindings", http.StatusInternalServerError)
-		return
+	clusters := s.Clusters
+	if r.URL.Query().Get("cluster") != "" {
+		a, ok := s.appFor(w, r)
+		if !ok {
+			return
+		}
+		clusters = []internal.App{a}
+	}
+
+	data := []internal.Data{}
+	for _, a := range clusters {
+		if !s.checkReady(w, a) {
+			return
+		}
+
+		// Get the bindings
+		bindings, err := internal.Generator(a).GetBindings()
+		if err != nil {
+			s.App.Logger.Error().Err(err).Str("cluster", a.Cluster).Msg("Failed to get bindings")
+			http.Error(w, "Failed to get bindings", http.StatusInternalServerError)
+			return
+		}
+
+		roles, err := internal.Generator(a).GetRoles()
+		if err != nil {
+			s.App.Logger.Error().Err(err).Str("cluster", a.Cluster).Msg("Failed to get roles")
+			http.Error(w, "Failed to get roles", http.StatusInternalServerError)
+			return
+		}
+
+		clusterData := internal.GenerateData(bindings, a.Cluster)
+		internal.AttachFindings(clusterData, internal.FindFindings(bindings, roles, a.Cluster, false))
+		internal.AttachAggregation(clusterData, roles)
+		data = append(data, clusterData...)
 	}
 
-	data := internal.GenerateData(bindings)
 	byteData, err := json.Marshal(data)
 	if err != nil {
 		s.App.Logger.Error().Err(err).Msg("Failed to marshal data")
@@ -203,6 +319,73 @@ func (s *Serve) dataHandler(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+// rolesHandler serves the Roles and ClusterRoles of the selected cluster, or of every cluster when none is selected.
+func (s *Serve) rolesHandler(w http.ResponseWriter, r *http.Request) {
+	cacheControllers(w)
+
+	clusters := s.Clusters
+	if r.URL.Query().Get("cluster") != "" {
+		a, ok := s.appFor(w, r)
+		if !ok {
+			return
+		}
+		clusters = []internal.App{a}
+	}
+
+	roles := []internal.RoleData{}
+	for _, a := range clusters {
+		if !s.checkReady(w, a) {
+			return
+		}
+
+		clusterRoles, err := internal.Generator(a).GetRoles()
+		if err != nil {
+			s.App.Logger.Error().Err(err).Str("cluster", a.Cluster).Msg("Failed to get roles")
+			http.Error(w, "Failed to get roles", http.StatusInternalServerError)
+			return
+		}
+
+		roles = append(roles, internal.GenerateRoleData(clusterRoles, a.Cluster)...)
+	}
+
+	s.writeJSON(w, roles)
+}
+
+// workloadsHandler serves the workloads of the selected cluster, or of every cluster when none is selected.
+func (s *Serve) workloadsHandler(w http.ResponseWriter, r *http.Request) {
+	cacheControllers(w)
+
+	clusters := s.Clusters
+	if r.URL.Query().Get("cluster") != "" {
+		a, ok := s.appFor(w, r)
+		if !ok {
+			return
+		}
+		clusters = []internal.App{a}
+	}
+
+	workloads := []internal.Workload{}
+	for _, a := range clusters {
+		if !s.checkReady(w, a) {
+			return
+		}
+
+		found, err := findWorkloads(a)
+		if err != nil {
+			s.App.Logger.Error().Err(err).Str("cluster", a.Cluster).Msg("Failed to find workloads")
+			http.Error(w, "Failed to find workloads", http.StatusInternalServerError)
+			return
+		}
+		for _, warning := range found.Warnings {
+			s.App.Logger.Warn().Str("cluster", a.Cluster).Str("warning", warning).Msg("Serving without some workloads")
+		}
+
+		workloads = append(workloads, found.Workloads...)
+	}
+
+	s.writeJSON(w, workloads)
+}
+
 func (s *Serve) whatIfHandler(w http.ResponseWriter, r *http.Request) {
 	cacheControllers(w)
 
@@ -212,6 +395,11 @@ func (s *Serve) whatIfHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	a, ok := s.appFor(w, r)
+	if !ok {
+		return
+	}
+
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		s.App.Logger.Error().Err(err).Msg("Failed to read request body")
@@ -221,6 +409,8 @@ func (s *Serve) whatIfHandler(w http.ResponseWriter, r *http.Request) {
 
 	var input struct {
 		Yaml string `json:"yaml"`
+		// Operation is either apply, the default, or delete
+		Operation string `json:"operation"`
 	}
 
 	if err := json.Unmarshal(body, &input); err != nil {
@@ -229,71 +419,465 @@ func (s *Serve) whatIfHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var obj interface{}
-	if err := yaml.Unmarshal([]byte(input.Yaml), &obj); err != nil {
+	objects, err := internal.DecodeObjects([]byte(input.Yaml))
+	if err != nil {
 		s.App.Logger.Error().Err(err).Msg("Invalid YAML format")
 		http.Error(w, "Invalid YAML format", http.StatusBadRequest)
 		return
 	}
 
-	var responseData struct {
-		Nodes []internal.Node `json:"nodes"`
-		Links []internal.Link `json:"links"`
-	}
-
-	if obj == nil {
+	if len(objects) == 0 {
 		s.App.Logger.Error().Msg("Empty object")
 		http.Error(w, "Empty object", http.StatusBadRequest)
 		return
 	}
 
-	decode := kyaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
-	uObj := &unstructured.Unstructured{}
-	_, _, err = decode.Decode([]byte(input.Yaml), nil, uObj)
+	if input.Operation == "" {
+		input.Operation = internal.WhatIfApply
+	}
+	if input.Operation != internal.WhatIfApply && input.Operation != internal.WhatIfDelete {
+		s.App.Logger.Error().Str("operation", input.Operation).Msg("Invalid what-if operation")
+		http.Error(w, "Invalid what-if operation", http.StatusBadRequest)
+		return
+	}
+
+	responseData, err := internal.WhatIfGenerator(a).WhatIf(objects, input.Operation)
+	if errors.Is(err, internal.ErrUnsupportedObject) {
+		s.App.Logger.Error().Err(err).Msg("Unsupported resource type")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		s.App.Logger.Error().Err(err).Msg("Failed to parse object")
-		http.Error(w, "Failed to parse object", http.StatusBadRequest)
+		s.App.Logger.Error().Err(err).Msg("Failed to evaluate what-if")
+		http.Error(w, "Failed to evaluate what-if", http.StatusInternalServerError)
 		return
 	}
 
-	switch obj.(map[interface{}]interface{})["kind"] {
-	case "ClusterRoleBinding":
-		crb := &v1.ClusterRoleBinding{}
-		err = runtime.DefaultUnstructuredConverter.FromUnstructured(uObj.UnstructuredContent(), crb)
-		if err != nil {
-			s.App.Logger.Error().Err(err).Msg("Failed to convert to ClusterRoleBinding")
-			http.Error(w, "Failed to convert to ClusterRoleBinding", http.StatusBadRequest)
+	respData, err := json.Marshal(responseData)
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Failed to marshal response data")
+		http.Error(w, "Failed to marshal response data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(respData)
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Failed to write response data")
+		http.Error(w, "Failed to write response data", http.StatusInternalServerError)
+		return
+	}
+}
+
+// noNamespace is the namespace segment of the subject routes for the subjects that are not namespaced.
+const noNamespace = "-"
+
+// subjectFromPath returns the subject of the /api/subjects/{kind}/{namespace}/{name} routes. Users and Groups
+// must have the "-" placeholder as namespace, and ServiceAccounts a namespace.
+func subjectFromPath(r *http.Request) (v1.Subject, error) {
+	namespace := r.PathValue("namespace")
+	if namespace == noNamespace {
+		namespace = ""
+	}
+
+	subject, err := internal.NewSubject(r.PathValue("kind"), namespace, r.PathValue("name"))
+	if err != nil {
+		return v1.Subject{}, err
+	}
+	if subject.Kind != v1.ServiceAccountKind && namespace != "" {
+		return v1.Subject{}, fmt.Errorf("%s subjects are not namespaced, the namespace must be %q", subject.Kind, noNamespace)
+	}
+	return subject, nil
+}
+
+func (s *Serve) permissionsHandler(w http.ResponseWriter, r *http.Request) {
+	cacheControllers(w)
+
+	subject, err := subjectFromPath(r)
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Invalid subject")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a, ok := s.appFor(w, r)
+	if !ok {
+		return
+	}
+
+	permissions, err := resolvePermissions(a, subject)
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Failed to resolve permissions")
+		http.Error(w, "Failed to resolve permissions", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, permissions)
+}
+
+// subjectClustersHandler serves the permissions of the subject in every cluster.
+func (s *Serve) subjectClustersHandler(w http.ResponseWriter, r *http.Request) {
+	cacheControllers(w)
+
+	subject, err := subjectFromPath(r)
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Invalid subject")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]internal.SubjectPermissions, 0, len(s.Clusters))
+	for _, a := range s.Clusters {
+		if !s.checkReady(w, a) {
 			return
 		}
 
-		responseData = internal.WhatIfGenerator(app).ProcessClusterRoleBinding(crb)
-	case "RoleBinding":
-		rb := &v1.RoleBinding{}
-		err = runtime.DefaultUnstructuredConverter.FromUnstructured(uObj.UnstructuredContent(), rb)
+		permissions, err := resolvePermissions(a, subject)
 		if err != nil {
-			s.App.Logger.Error().Err(err).Msg("Failed to convert to RoleBinding")
-			http.Error(w, "Failed to convert to ClusterRoleBinding", http.StatusBadRequest)
+			s.App.Logger.Error().Err(err).Str("cluster", a.Cluster).Msg("Failed to resolve permissions")
+			http.Error(w, "Failed to resolve permissions", http.StatusInternalServerError)
 			return
 		}
-		responseData = internal.WhatIfGenerator(app).ProcessRoleBinding(rb)
-	default:
-		s.App.Logger.Error().Msg("Unsupported resource type")
-		http.Error(w, "Unsupported resource type", http.StatusBadRequest)
+		results = append(results, permissions)
+	}
+
+	s.writeJSON(w, results)
+}
+
+// eventsHandler streams the binding changes of the selected cluster, or of every cluster
+// when none is selected, as Server-Sent Events.
+func (s *Serve) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.App.Logger.Error().Msg("Streaming is not supported")
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
 		return
 	}
 
-	respData, err := json.Marshal(responseData)
+	clusters := s.Clusters
+	if r.URL.Query().Get("cluster") != "" {
+		a, ok := s.appFor(w, r)
+		if !ok {
+			return
+		}
+		clusters = []internal.App{a}
+	}
+
+	events := make(chan []internal.Event)
+	for _, a := range clusters {
+		ch, cancel := s.Broadcasters[a.Cluster].Subscribe()
+		defer cancel()
+
+		go func() {
+			for batch := range ch {
+				select {
+				case events <- batch:
+				case <-r.Context().Done():
+					return
+				}
+			}
+			// The subscription was closed because the client fell behind
+			select {
+			case events <- nil:
+			case <-r.Context().Done():
+			}
+		}()
+	}
+
+	cacheControllers(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+		case batch := <-events:
+			if batch == nil {
+				return
+			}
+			byteData, err := json.Marshal(batch)
+			if err != nil {
+				s.App.Logger.Error().Err(err).Msg("Failed to marshal events")
+				continue
+			}
+			_, _ = fmt.Fprintf(w, "event: bindings\ndata: %s\n\n", byteData)
+		}
+		flusher.Flush()
+	}
+}
+
+func (s *Serve) clustersHandler(w http.ResponseWriter, _ *http.Request) {
+	cacheControllers(w)
+
+	clusters := make([]string, 0, len(s.Clusters))
+	for _, a := range s.Clusters {
+		clusters = append(clusters, a.Cluster)
+	}
+
+	s.writeJSON(w, clusters)
+}
+
+func (s *Serve) whoCanHandler(w http.ResponseWriter, r *http.Request) {
+	cacheControllers(w)
+
+	query := r.URL.Query()
+	if query.Get("verb") == "" || query.Get("resource") == "" {
+		s.App.Logger.Error().Msg("Missing verb or resource")
+		http.Error(w, "Missing verb or resource", http.StatusBadRequest)
+		return
+	}
+
+	resource, group, subresource := internal.ParseResource(query.Get("resource"))
+	if query.Has("apiGroup") {
+		group = query.Get("apiGroup")
+	}
+	if query.Has("subresource") {
+		subresource = query.Get("subresource")
+	}
+
+	attrs := internal.ResourceAttributes{
+		Verb:          query.Get("verb"),
+		APIGroup:      group,
+		Resource:      resource,
+		Subresource:   subresource,
+		Name:          query.Get("resourceName"),
+		Namespace:     query.Get("namespace"),
+		AllNamespaces: query.Get("allNamespaces") == "true",
+	}
+
+	a, ok := s.appFor(w, r)
+	if !ok {
+		return
+	}
+
+	bindings, err := internal.Generator(a).GetBindings()
 	if err != nil {
-		s.App.Logger.Error().Err(err).Msg("Failed to marshal response data")
-		http.Error(w, "Failed to marshal response data", http.StatusInternalServerError)
+		s.App.Logger.Error().Err(err).Msg("Failed to get bindings")
+		http.Error(w, "Failed to get bindings", http.StatusInternalServerError)
+		return
+	}
+
+	roles, err := internal.Generator(a).GetRoles()
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Failed to get roles")
+		http.Error(w, "Failed to get roles", http.StatusInternalServerError)
+		return
+	}
+
+	identities, err := a.GetIdentities()
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Failed to get identities")
+		http.Error(w, "Failed to get identities", http.StatusInternalServerError)
+		return
+	}
+
+	results := internal.WhoCan(bindings, roles, attrs)
+	for i := range results {
+		results[i].Cluster = a.Cluster
+		if results[i].Subject.Kind == v1.GroupKind {
+			results[i].Members = identities.Members(results[i].Subject.Name)
+		}
+	}
+
+	s.writeJSON(w, results)
+}
+
+func (s *Serve) escalationsHandler(w http.ResponseWriter, r *http.Request) {
+	cacheControllers(w)
+
+	a, ok := s.appFor(w, r)
+	if !ok {
+		return
+	}
+
+	escalations, err := findEscalations(a, r.URL.Query().Get("includeSystem") == "true")
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Failed to find escalations")
+		http.Error(w, "Failed to find escalations", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, escalations)
+}
+
+func (s *Serve) hygieneHandler(w http.ResponseWriter, r *http.Request) {
+	cacheControllers(w)
+
+	a, ok := s.appFor(w, r)
+	if !ok {
+		return
+	}
+
+	issues, err := findHygieneIssues(a, r.URL.Query().Get("includeSystem") == "true")
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Failed to find hygiene issues")
+		http.Error(w, "Failed to find hygiene issues", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, issues)
+}
+
+// usageHandler serves the usage of the granted permissions according to the audit logs given to the server.
+func (s *Serve) usageHandler(w http.ResponseWriter, r *http.Request) {
+	cacheControllers(w)
+
+	if s.AuditEvents == nil {
+		http.Error(w, "No audit logs loaded", http.StatusNotFound)
+		return
+	}
+
+	a, ok := s.appFor(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := computeUsage(a, s.AuditEvents)
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Failed to compute usage")
+		http.Error(w, "Failed to compute usage", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, report)
+}
+
+// leastPrivilegeHandler serves the minimal roles of the subject generated from the audit logs given to the
+// server, with their YAML to preview them with the what-if handler.
+func (s *Serve) leastPrivilegeHandler(w http.ResponseWriter, r *http.Request) {
+	cacheControllers(w)
+
+	if s.AuditEvents == nil {
+		http.Error(w, "No audit logs loaded", http.StatusNotFound)
+		return
+	}
+
+	subject, err := subjectFromPath(r)
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Invalid subject")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	var filter internal.AuditFilter
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if query.Get(name) == "" {
+			continue
+		}
+		if *t, err = parseTime(query.Get(name), time.Now()); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid %s: %v", name, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	a, ok := s.appFor(w, r)
+	if !ok {
+		return
+	}
+
+	var events []internal.AuditEvent
+	for _, event := range s.AuditEvents {
+		if filter.Includes(event) {
+			events = append(events, event)
+		}
+	}
+
+	lp, err := generateLeastPrivilege(a, events, subject, query.Get("roleName"))
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Failed to generate least privilege roles")
+		http.Error(w, "Failed to generate least privilege roles", http.StatusInternalServerError)
+		return
+	}
+
+	content, err := lp.YAML()
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Failed to render least privilege roles")
+		http.Error(w, "Failed to render least privilege roles", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, struct {
+		*internal.LeastPrivilege
+		Yaml string `json:"yaml"`
+	}{lp, string(content)})
+}
+
+// diffHandler compares the snapshot uploaded as "old" with the snapshot uploaded as "new",
+// or with the currently served state when there is none.
+func (s *Serve) diffHandler(w http.ResponseWriter, r *http.Request) {
+	cacheControllers(w)
+
+	old, err := s.readSnapshotFile(r, "old")
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Failed to read old snapshot")
+		http.Error(w, "Failed to read old snapshot", http.StatusBadRequest)
+		return
+	}
+	if old == nil {
+		http.Error(w, "old snapshot is required", http.StatusBadRequest)
+		return
+	}
+
+	current, err := s.readSnapshotFile(r, "new")
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Failed to read new snapshot")
+		http.Error(w, "Failed to read new snapshot", http.StatusBadRequest)
+		return
+	}
+
+	if current == nil {
+		a, ok := s.appFor(w, r)
+		if !ok {
+			return
+		}
+
+		current, err = a.TakeSnapshot(internal.SnapshotMetadata{})
+		if err != nil {
+			s.App.Logger.Error().Err(err).Msg("Failed to read current state")
+			http.Error(w, "Failed to read current state", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	s.writeJSON(w, internal.DiffStores(old.Store, current.Store))
+}
+
+// readSnapshotFile reads the snapshot uploaded in the given form field, it returns nil if there is none.
+func (s *Serve) readSnapshotFile(r *http.Request, field string) (*internal.Snapshot, error) {
+	file, _, err := r.FormFile(field)
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return internal.ReadSnapshot(file)
+}
+
+func (s *Serve) writeJSON(w http.ResponseWriter, data interface{}) {
+	byteData, err := json.Marshal(data)
+	if err != nil {
+		s.App.Logger.Error().Err(err).Msg("Failed to marshal data")
+		http.Error(w, "Failed to marshal data", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	_, err = w.Write(respData)
+	_, err = w.Write(byteData)
 	if err != nil {
-		s.App.Logger.Error().Err(err).Msg("Failed to write response data")
-		http.Error(w, "Failed to write response data", http.StatusInternalServerError)
+		s.App.Logger.Error().Err(err).Msg("Failed to write data")
+		http.Error(w, "Failed to write data", http.StatusInternalServerError)
 		return
 	}
 }
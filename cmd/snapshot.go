@@ -0,0 +1,94 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pehlicd/rbac-wizard/internal"
+)
+
+// snapshotCmd represents the snapshot command
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Work with snapshots of the RBAC objects of a cluster",
+	Long: `Work with snapshots of the RBAC objects of a cluster. Snapshots are portable archives that can be browsed later
+without access to the cluster with 'rbac-wizard serve --snapshot'.`,
+}
+
+// snapshotSaveCmd represents the snapshot save command
+var snapshotSaveCmd = &cobra.Command{
+	Use:   "save",
+	Short: "Save a snapshot of the RBAC objects of a cluster",
+	Long: `Save a snapshot of every RBAC object, ServiceAccount and Namespace of the cluster the current kubeconfig points to,
+together with the cluster metadata and the time the snapshot was taken.`,
+	Example: `  rbac-wizard snapshot save -o cluster.tar.gz
+  rbac-wizard serve --snapshot cluster.tar.gz`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		src := sourceFromFlags(cmd)
+
+		a, err := newApp(src)
+		if err != nil {
+			return err
+		}
+
+		metadata := internal.SnapshotMetadata{ToolVersion: versionString}
+		if a.KubeClient != nil {
+			metadata.Context, metadata.Server, err = internal.GetClusterInfo(a.Cluster)
+			if err != nil {
+				return err
+			}
+		}
+
+		snapshot, err := a.TakeSnapshot(metadata)
+		if err != nil {
+			return fmt.Errorf("failed to take snapshot: %w", err)
+		}
+
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err := snapshot.Write(f); err != nil {
+			return fmt.Errorf("failed to write snapshot: %w", err)
+		}
+
+		fmt.Printf("Snapshot saved to %s\n", output)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.AddCommand(snapshotSaveCmd)
+
+	snapshotSaveCmd.Flags().StringP("output", "o", "rbac-wizard-snapshot.tar.gz", "Path of the snapshot archive")
+	addSourceFlags(snapshotSaveCmd)
+}
@@ -0,0 +1,167 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/pehlicd/rbac-wizard/internal"
+	"github.com/pehlicd/rbac-wizard/internal/logger"
+)
+
+// source describes where the objects of the apps are read from.
+// The cluster of the current kubeconfig is used when it is empty.
+type source struct {
+	Paths       []string
+	Snapshot    string
+	Contexts    []string
+	AllContexts bool
+	// IdentityFiles map users to the groups of an external identity source
+	IdentityFiles []string
+}
+
+// addSourceFlags adds the flags to read objects from files or a snapshot instead of a cluster.
+func addSourceFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("from-file", nil, "Read objects from a YAML or JSON file instead of a cluster, can be repeated")
+	cmd.Flags().StringArray("from-dir", nil, "Read objects from the YAML and JSON files of a directory instead of a cluster, can be repeated")
+	cmd.Flags().String("snapshot", "", "Read objects from a snapshot taken with 'rbac-wizard snapshot save' instead of a cluster")
+	cmd.Flags().StringArray("context", nil, "Kubeconfig context of a cluster to read from, can be repeated")
+	cmd.Flags().Bool("all-contexts", false, "Read from the clusters of every kubeconfig context")
+	cmd.Flags().StringArray("identity-file", nil, "YAML, JSON, CSV or LDIF file mapping users to the groups of an identity provider, can be repeated")
+	cmd.MarkFlagsMutuallyExclusive("from-file", "snapshot", "context", "all-contexts")
+	cmd.MarkFlagsMutuallyExclusive("from-dir", "snapshot", "context", "all-contexts")
+}
+
+// sourceFromFlags returns the source given with the source flags.
+func sourceFromFlags(cmd *cobra.Command) source {
+	files, _ := cmd.Flags().GetStringArray("from-file")
+	dirs, _ := cmd.Flags().GetStringArray("from-dir")
+	snapshot, _ := cmd.Flags().GetString("snapshot")
+	contexts, _ := cmd.Flags().GetStringArray("context")
+	allContexts, _ := cmd.Flags().GetBool("all-contexts")
+	identityFiles, _ := cmd.Flags().GetStringArray("identity-file")
+	return source{
+		Paths:         append(files, dirs...),
+		Snapshot:      snapshot,
+		Contexts:      contexts,
+		AllContexts:   allContexts,
+		IdentityFiles: identityFiles,
+	}
+}
+
+// connectAll creates one app per cluster of the source.
+func connectAll(l *zerolog.Logger, src source) ([]internal.App, error) {
+	identities, err := internal.LoadIdentityMap(src.IdentityFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	contexts := src.Contexts
+	if src.AllContexts {
+		var err error
+		contexts, err = internal.GetContexts()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(contexts) == 0 {
+		a := internal.App{Logger: l, Identities: identities}
+		if err := connect(&a, src); err != nil {
+			return nil, err
+		}
+		return []internal.App{a}, nil
+	}
+
+	apps := make([]internal.App, 0, len(contexts))
+	for _, context := range contexts {
+		kubeClient, err := internal.GetClientsetForContext(context)
+		if err != nil {
+			return nil, err
+		}
+		apps = append(apps, internal.App{
+			KubeClient: kubeClient,
+			Logger:     l,
+			Cluster:    context,
+			Identities: identities,
+		})
+	}
+
+	return apps, nil
+}
+
+// connect points the app at the objects of the source.
+func connect(a *internal.App, src source) error {
+	if src.Snapshot != "" {
+		snapshot, err := internal.LoadSnapshot(src.Snapshot)
+		if err != nil {
+			return fmt.Errorf("failed to load snapshot: %w", err)
+		}
+		a.Logger.Info().
+			Str("context", snapshot.Metadata.Context).
+			Str("server", snapshot.Metadata.Server).
+			Time("createdAt", snapshot.Metadata.CreatedAt).
+			Msg("Loaded snapshot")
+		a.Store = snapshot.Store
+		a.Cluster = snapshot.Metadata.Context
+		return nil
+	}
+
+	if len(src.Paths) > 0 {
+		store, err := internal.LoadFiles(src.Paths, a.Logger)
+		if err != nil {
+			return fmt.Errorf("failed to load objects from files: %w", err)
+		}
+		a.Store = store
+		return nil
+	}
+
+	kubeClient, err := internal.GetClientset()
+	if err != nil {
+		return err
+	}
+	a.KubeClient = kubeClient
+	a.Cluster, _, _ = internal.GetClusterInfo("")
+
+	return nil
+}
+
+// newApps creates the apps of the source with logging disabled for the command line tools.
+func newApps(src source) ([]internal.App, error) {
+	return connectAll(logger.New("off", "text"), src)
+}
+
+// newApp creates the app of a source that refers to a single cluster.
+func newApp(src source) (internal.App, error) {
+	apps, err := newApps(src)
+	if err != nil {
+		return internal.App{}, err
+	}
+	if len(apps) != 1 {
+		return internal.App{}, fmt.Errorf("a single cluster must be given, got %d", len(apps))
+	}
+	return apps[0], nil
+}
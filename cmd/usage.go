@@ -0,0 +1,188 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pehlicd/rbac-wizard/internal"
+)
+
+// usageCmd represents the usage command
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show which granted permissions are used according to audit logs",
+	Long: `Show which granted permissions are actually used. This will read the JSON lines audit logs of the API server,
+attribute every allowed request to the binding and rule that authorized it and count the requests of every rule. Rules
+that authorized no request are the candidates to trim from roles. The audit logs must be of the cluster that is read,
+and should cover a period long enough to include infrequent jobs. Bindings managed by Kubernetes are left out unless
+--include-system is given.`,
+	Example: `  rbac-wizard usage --audit-log /var/log/kubernetes/audit.log
+  rbac-wizard usage --audit-log ./audit-logs --since 720h --unused
+  rbac-wizard usage --audit-log audit.log --snapshot cluster.tar.gz -o json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		auditLogs, _ := cmd.Flags().GetStringArray("audit-log")
+		unusedOnly, _ := cmd.Flags().GetBool("unused")
+		includeSystem, _ := cmd.Flags().GetBool("include-system")
+		output, _ := cmd.Flags().GetString("output")
+
+		filter, err := auditFilterFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		events, err := internal.LoadAuditEvents(auditLogs, filter)
+		if err != nil {
+			return fmt.Errorf("failed to read audit logs: %w", err)
+		}
+
+		a, err := newApp(sourceFromFlags(cmd))
+		if err != nil {
+			return err
+		}
+
+		report, err := computeUsage(a, events)
+		if err != nil {
+			return err
+		}
+
+		grants := []internal.GrantUsage{}
+		for _, grant := range report.Grants {
+			if (includeSystem || !grant.System) && (!unusedOnly || grant.Unused()) {
+				grants = append(grants, grant)
+			}
+		}
+		report.Grants = grants
+
+		bindings := []internal.BindingUsage{}
+		for _, binding := range report.Bindings {
+			if (includeSystem || !binding.System) && (!unusedOnly || binding.UnusedRules > 0) {
+				bindings = append(bindings, binding)
+			}
+		}
+		report.Bindings = bindings
+
+		return printUsage(report, output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(usageCmd)
+
+	usageCmd.Flags().StringArray("audit-log", nil, "JSON lines audit log file or directory of audit logs, can be repeated")
+	usageCmd.Flags().Bool("unused", false, "Only show the rules that authorized no request")
+	usageCmd.Flags().Bool("include-system", false, "Include the bindings managed by Kubernetes")
+	usageCmd.Flags().StringP("output", "o", "table", "Output format [table, json]")
+	addAuditFilterFlags(usageCmd)
+	addSourceFlags(usageCmd)
+	_ = usageCmd.MarkFlagRequired("audit-log")
+}
+
+// addAuditFilterFlags adds the flags to select the audit events of a time window.
+func addAuditFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().String("since", "", "Only read the requests after this time, given in RFC 3339 or as a duration before now such as 24h")
+	cmd.Flags().String("until", "", "Only read the requests before this time, given in RFC 3339 or as a duration before now such as 24h")
+}
+
+// auditFilterFromFlags returns the time window given with the audit filter flags.
+func auditFilterFromFlags(cmd *cobra.Command) (internal.AuditFilter, error) {
+	var filter internal.AuditFilter
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value, _ := cmd.Flags().GetString(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := parseTime(value, time.Now())
+		if err != nil {
+			return filter, fmt.Errorf("invalid --%s: %w", name, err)
+		}
+		*t = parsed
+	}
+	return filter, nil
+}
+
+// parseTime parses a time in RFC 3339, or a duration before now.
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// computeUsage attributes the requests of the audit events to the grants of the cluster of the app.
+func computeUsage(a internal.App, events []internal.AuditEvent) (internal.UsageReport, error) {
+	bindings, err := internal.Generator(a).GetBindings()
+	if err != nil {
+		return internal.UsageReport{}, fmt.Errorf("failed to get bindings: %w", err)
+	}
+
+	roles, err := internal.Generator(a).GetRoles()
+	if err != nil {
+		return internal.UsageReport{}, fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	return internal.ComputeUsage(bindings, roles, events, a.Cluster), nil
+}
+
+func printUsage(report internal.UsageReport, output string) error {
+	switch output {
+	case "json":
+		return printJSON(report)
+	case "table":
+		fmt.Printf("Requests: %d allowed, %d denied, %d not authorized by RBAC\n\n", report.Requests, report.Denied, report.Unattributed)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "BINDING\tROLE\tRULE\tREQUESTS\tLAST USED\tUSED BY")
+		for _, u := range report.Grants {
+			binding := u.Binding.Kind + "/" + u.Binding.Name
+			if u.Binding.Namespace != "" {
+				binding = u.Binding.Kind + "/" + u.Binding.Namespace + "/" + u.Binding.Name
+			}
+			role := u.RoleRef.Kind + "/" + u.RoleRef.Name
+			if u.AggregatedFrom != "" {
+				role += " (aggregated from " + u.AggregatedFrom + ")"
+			}
+			lastUsed := "never"
+			if u.LastUsed != nil {
+				lastUsed = u.LastUsed.Format(time.RFC3339)
+			}
+			usedBy := make([]string, 0, len(u.UsedBy))
+			for _, s := range u.UsedBy {
+				usedBy = append(usedBy, subjectName(s.Subject)+" ("+strconv.Itoa(s.Requests)+")")
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", binding, role, internal.FormatRule(u.Rule),
+				u.Requests, lastUsed, orDash(strings.Join(usedBy, ", "), "-"))
+		}
+		return w.Flush()
+	}
+
+	return fmt.Errorf("unsupported output format %q", output)
+}
@@ -0,0 +1,158 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/rbac/v1"
+
+	"github.com/pehlicd/rbac-wizard/internal"
+)
+
+// whoCanCmd represents the who-can command
+var whoCanCmd = &cobra.Command{
+	Use:   "who-can VERB RESOURCE",
+	Short: "Show which subjects can perform an action",
+	Long: `Show which subjects can perform an action on a resource. Every binding in the cluster is evaluated together with
+the rules of the role it references, including "*" wildcards in verbs, resources and API groups. The resource can be
+given in the "resource[.group][/subresource]" form, when the group is omitted rules of any API group match.`,
+	Example: `  rbac-wizard who-can delete secrets -n payments
+  rbac-wizard who-can create pods/exec -A
+  rbac-wizard who-can get deployments.apps --resource-name my-app -n default`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		attrs := whoCanAttributes(cmd, args[0], args[1])
+		output, _ := cmd.Flags().GetString("output")
+
+		apps, err := newApps(sourceFromFlags(cmd))
+		if err != nil {
+			return err
+		}
+
+		results := []internal.WhoCanResult{}
+		for _, a := range apps {
+			bindings, err := internal.Generator(a).GetBindings()
+			if err != nil {
+				return fmt.Errorf("failed to get bindings: %w", err)
+			}
+
+			roles, err := internal.Generator(a).GetRoles()
+			if err != nil {
+				return fmt.Errorf("failed to get roles: %w", err)
+			}
+
+			identities, err := a.GetIdentities()
+			if err != nil {
+				return fmt.Errorf("failed to get identities: %w", err)
+			}
+
+			for _, r := range internal.WhoCan(bindings, roles, attrs) {
+				r.Cluster = a.Cluster
+				if r.Subject.Kind == v1.GroupKind {
+					r.Members = identities.Members(r.Subject.Name)
+				}
+				results = append(results, r)
+			}
+		}
+
+		return printWhoCan(results, output, len(apps) > 1)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(whoCanCmd)
+
+	whoCanCmd.Flags().StringP("namespace", "n", "", "Namespace of the request, cluster scoped when empty")
+	whoCanCmd.Flags().BoolP("all-namespaces", "A", false, "Match grants in any namespace")
+	whoCanCmd.Flags().String("subresource", "", "Subresource of the request")
+	whoCanCmd.Flags().String("resource-name", "", "Name of the resource of the request")
+	whoCanCmd.Flags().String("api-group", "", "API group of the resource, overrides the group given with the resource")
+	whoCanCmd.Flags().StringP("output", "o", "table", "Output format [table, json]")
+	addSourceFlags(whoCanCmd)
+}
+
+func whoCanAttributes(cmd *cobra.Command, verb string, resource string) internal.ResourceAttributes {
+	namespace, _ := cmd.Flags().GetString("namespace")
+	allNamespaces, _ := cmd.Flags().GetBool("all-namespaces")
+	subresource, _ := cmd.Flags().GetString("subresource")
+	resourceName, _ := cmd.Flags().GetString("resource-name")
+
+	name, group, sub := internal.ParseResource(resource)
+	if subresource == "" {
+		subresource = sub
+	}
+	if cmd.Flags().Changed("api-group") {
+		group, _ = cmd.Flags().GetString("api-group")
+	}
+
+	return internal.ResourceAttributes{
+		Verb:          verb,
+		APIGroup:      group,
+		Resource:      name,
+		Subresource:   subresource,
+		Name:          resourceName,
+		Namespace:     namespace,
+		AllNamespaces: allNamespaces,
+	}
+}
+
+func printWhoCan(results []internal.WhoCanResult, output string, multiCluster bool) error {
+	switch output {
+	case "json":
+		return printJSON(results)
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if multiCluster {
+			_, _ = fmt.Fprint(w, "CLUSTER\t")
+		}
+		_, _ = fmt.Fprintln(w, "SUBJECT\tNAMESPACE\tBINDING\tROLE\tRULE")
+		for _, r := range results {
+			if multiCluster {
+				_, _ = fmt.Fprintf(w, "%s\t", r.Cluster)
+			}
+			binding := r.Binding.Kind + "/" + r.Binding.Name
+			if r.Binding.Namespace != "" {
+				binding = r.Binding.Kind + "/" + r.Binding.Namespace + "/" + r.Binding.Name
+			}
+			role := r.RoleRef.Kind + "/" + r.RoleRef.Name
+			if r.AggregatedFrom != "" {
+				role += " (aggregated from " + r.AggregatedFrom + ")"
+			}
+			subject := r.Subject.Kind + "/" + r.Subject.Name
+			if len(r.Members) > 0 {
+				subject += " (" + strings.Join(r.Members, ", ") + ")"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				subject, orDash(r.Subject.Namespace, "-"), binding,
+				role, internal.FormatRule(r.Rule))
+		}
+		return w.Flush()
+	}
+
+	return fmt.Errorf("unsupported output format %q", output)
+}
@@ -0,0 +1,152 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pehlicd/rbac-wizard/internal"
+)
+
+// workloadsCmd represents the workloads command
+var workloadsCmd = &cobra.Command{
+	Use:   "workloads",
+	Short: "Show the service accounts the workloads of the cluster run as",
+	Long: `Show the Pods, Deployments, StatefulSets, DaemonSets, Jobs and CronJobs of the cluster with the service account
+their pods run as, whether its token is mounted into the pods and whether it is a cluster admin or can escalate to
+cluster admin. Pods and Jobs created by a controller are represented by their owner.`,
+	Example: `  rbac-wizard workloads
+  rbac-wizard workloads --cluster-admin
+  rbac-wizard workloads -n payments -o json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		namespace, _ := cmd.Flags().GetString("namespace")
+		adminOnly, _ := cmd.Flags().GetBool("cluster-admin")
+		output, _ := cmd.Flags().GetString("output")
+
+		apps, err := newApps(sourceFromFlags(cmd))
+		if err != nil {
+			return err
+		}
+
+		results := []internal.Workload{}
+		for _, a := range apps {
+			found, err := findWorkloads(a)
+			if err != nil {
+				return err
+			}
+			for _, warning := range found.Warnings {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", warning)
+			}
+			for _, w := range found.Workloads {
+				if (namespace == "" || w.Namespace == namespace) && (!adminOnly || w.ClusterAdmin) {
+					results = append(results, w)
+				}
+			}
+		}
+
+		return printWorkloads(results, output, len(apps) > 1)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(workloadsCmd)
+
+	workloadsCmd.Flags().StringP("namespace", "n", "", "Only show the workloads of this namespace")
+	workloadsCmd.Flags().Bool("cluster-admin", false, "Only show the workloads that run with cluster admin rights")
+	workloadsCmd.Flags().StringP("output", "o", "table", "Output format [table, json]")
+	addSourceFlags(workloadsCmd)
+}
+
+// workloadsResult holds the workloads of a cluster and warnings about the kinds of workloads that could not be listed.
+type workloadsResult struct {
+	Workloads []internal.Workload
+	Warnings  []string
+}
+
+// findWorkloads returns the workloads of the cluster of the app with the service accounts they run as.
+// The workloads of the kinds that cannot be listed are left out and reported as warnings.
+func findWorkloads(a internal.App) (workloadsResult, error) {
+	bindings, err := internal.Generator(a).GetBindings()
+	if err != nil {
+		return workloadsResult{}, fmt.Errorf("failed to get bindings: %w", err)
+	}
+
+	roles, err := internal.Generator(a).GetRoles()
+	if err != nil {
+		return workloadsResult{}, fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	serviceAccounts, err := a.GetServiceAccounts()
+	if err != nil {
+		return workloadsResult{}, fmt.Errorf("failed to get service accounts: %w", err)
+	}
+
+	objects, unavailable := a.GetWorkloads()
+	result := workloadsResult{Workloads: internal.ResolveWorkloads(objects, serviceAccounts, bindings, roles, a.Cluster)}
+	if unavailable != nil {
+		result.Warnings = append(result.Warnings, unavailable.Error())
+	}
+	return result, nil
+}
+
+func printWorkloads(workloads []internal.Workload, output string, multiCluster bool) error {
+	switch output {
+	case "json":
+		return printJSON(workloads)
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if multiCluster {
+			_, _ = fmt.Fprint(w, "CLUSTER\t")
+		}
+		_, _ = fmt.Fprintln(w, "WORKLOAD\tSERVICE ACCOUNT\tTOKEN MOUNTED\tCLUSTER ADMIN")
+		for _, workload := range workloads {
+			if multiCluster {
+				_, _ = fmt.Fprintf(w, "%s\t", workload.Cluster)
+			}
+			admin := "no"
+			if workload.Escalation != nil {
+				admin = "via " + formatPath(workload.Escalation.Path)
+			} else if workload.ClusterAdmin {
+				admin = "yes"
+			}
+			_, _ = fmt.Fprintf(w, "%s/%s\t%s\t%s\t%s\n",
+				workload.Kind, objectName(workload.Namespace, workload.Name), workload.ServiceAccount,
+				yesNo(workload.AutomountToken), admin)
+		}
+		return w.Flush()
+	}
+
+	return fmt.Errorf("unsupported output format %q", output)
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,202 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"reflect"
+	"sort"
+
+	v1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// AggregatedRule is a rule of a ClusterRole together with the ClusterRole that defines it. For ClusterRoles with an
+// aggregationRule, that is the component ClusterRole the rule is aggregated from.
+type AggregatedRule struct {
+	ClusterRole string        `json:"clusterRole"`
+	Rule        v1.PolicyRule `json:"rule"`
+}
+
+// RoleComponent is a ClusterRole that contributes rules to an aggregated ClusterRole.
+type RoleComponent struct {
+	Id    string          `json:"id"`
+	Name  string          `json:"name"`
+	Rules []v1.PolicyRule `json:"rules"`
+}
+
+// AggregateClusterRoles resolves the rules of every ClusterRole by name. Like the aggregation controller of Kubernetes,
+// ClusterRoles with an aggregationRule get the rules of the ClusterRoles their selectors match, which may themselves
+// be aggregated, without duplicates. Aggregated ClusterRoles that match no ClusterRole keep the rules they were created
+// with, as the controller applies the empty union without the rules field, which leaves them in place.
+func AggregateClusterRoles(clusterRoles []v1.ClusterRole) map[string][]AggregatedRule {
+	byName := map[string]v1.ClusterRole{}
+	for _, cr := range clusterRoles {
+		byName[cr.Name] = cr
+	}
+
+	resolved := map[string][]AggregatedRule{}
+	var resolve func(name string, visiting map[string]bool) []AggregatedRule
+	resolve = func(name string, visiting map[string]bool) []AggregatedRule {
+		if rules, ok := resolved[name]; ok {
+			return rules
+		}
+
+		cr := byName[name]
+		own := make([]AggregatedRule, 0, len(cr.Rules))
+		for _, rule := range cr.Rules {
+			own = append(own, AggregatedRule{ClusterRole: name, Rule: rule})
+		}
+
+		// Aggregation cycles stop at the ClusterRole already being resolved
+		components := AggregationComponents(cr, clusterRoles)
+		if len(components) == 0 || visiting[name] {
+			return own
+		}
+
+		visiting[name] = true
+		defer delete(visiting, name)
+
+		rules := []AggregatedRule{}
+		for _, component := range components {
+			for _, rule := range resolve(component, visiting) {
+				if !containsRule(rules, rule.Rule) {
+					rules = append(rules, rule)
+				}
+			}
+		}
+		return rules
+	}
+
+	for _, cr := range clusterRoles {
+		resolved[cr.Name] = resolve(cr.Name, map[string]bool{})
+	}
+	return resolved
+}
+
+// AggregationComponents returns the names of the ClusterRoles the aggregationRule of the ClusterRole selects,
+// sorted by name. Like for the aggregation controller, an empty selector selects every ClusterRole.
+func AggregationComponents(cr v1.ClusterRole, clusterRoles []v1.ClusterRole) []string {
+	if cr.AggregationRule == nil {
+		return nil
+	}
+
+	var selectors []labels.Selector
+	for i := range cr.AggregationRule.ClusterRoleSelectors {
+		selector, err := metav1.LabelSelectorAsSelector(&cr.AggregationRule.ClusterRoleSelectors[i])
+		if err != nil {
+			continue
+		}
+		selectors = append(selectors, selector)
+	}
+
+	var components []string
+	for _, other := range clusterRoles {
+		if other.Name == cr.Name {
+			continue
+		}
+		for _, selector := range selectors {
+			if selector.Matches(labels.Set(other.Labels)) {
+				components = append(components, other.Name)
+				break
+			}
+		}
+	}
+
+	sort.Strings(components)
+	return components
+}
+
+// aggregationIndex maps the names of the ClusterRoles to the names of the aggregated ClusterRoles that select them.
+func aggregationIndex(clusterRoles []v1.ClusterRole) map[string][]string {
+	index := map[string][]string{}
+	for _, cr := range clusterRoles {
+		for _, component := range AggregationComponents(cr, clusterRoles) {
+			index[component] = append(index[component], cr.Name)
+		}
+	}
+	return index
+}
+
+// aggregatesOf returns the names of the ClusterRoles the ClusterRole is aggregated into, directly or through
+// other aggregated ClusterRoles, looked up in an index built by aggregationIndex.
+func aggregatesOf(name string, index map[string][]string) []string {
+	var aggregates []string
+	pending := []string{name}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		for _, aggregate := range index[current] {
+			if aggregate != name && !contains(aggregates, aggregate) {
+				aggregates = append(aggregates, aggregate)
+				pending = append(pending, aggregate)
+			}
+		}
+	}
+	return aggregates
+}
+
+// AttachAggregation sets the component ClusterRoles of the roles of the data rows, with the rules each of them
+// contributes.
+func AttachAggregation(data []Data, roles *Roles) {
+	if roles == nil || roles.ClusterRoles == nil {
+		return
+	}
+
+	aggregated := AggregateClusterRoles(roles.ClusterRoles.Items)
+	for i := range data {
+		data[i].RoleRefComponents = nil
+		if data[i].RoleRef.Kind == ClusterRoleKind {
+			data[i].RoleRefComponents = roleComponents(data[i].Cluster, data[i].RoleRef.Name, aggregated[data[i].RoleRef.Name])
+		}
+	}
+}
+
+// roleComponents groups the aggregated rules of a ClusterRole by the component ClusterRole they come from.
+func roleComponents(cluster string, name string, rules []AggregatedRule) []RoleComponent {
+	var components []RoleComponent
+	for _, rule := range rules {
+		if rule.ClusterRole == name {
+			continue
+		}
+		if n := len(components); n > 0 && components[n-1].Name == rule.ClusterRole {
+			components[n-1].Rules = append(components[n-1].Rules, rule.Rule)
+			continue
+		}
+		components = append(components, RoleComponent{
+			Id:    NodeID(cluster, ClusterRoleKind, "", rule.ClusterRole),
+			Name:  rule.ClusterRole,
+			Rules: []v1.PolicyRule{rule.Rule},
+		})
+	}
+	return components
+}
+
+func containsRule(rules []AggregatedRule, rule v1.PolicyRule) bool {
+	for _, r := range rules {
+		if reflect.DeepEqual(r.Rule, rule) {
+			return true
+		}
+	}
+	return false
+}
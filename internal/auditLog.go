@@ -0,0 +1,239 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/rbac/v1"
+)
+
+const (
+	// auditStageResponseComplete is the stage of audit events logged once the response has been sent
+	auditStageResponseComplete = "ResponseComplete"
+	// auditDecisionAnnotation and auditReasonAnnotation are set on audit events by the authorizers
+	auditDecisionAnnotation = "authorization.k8s.io/decision"
+	auditReasonAnnotation   = "authorization.k8s.io/reason"
+)
+
+// AuditEvent holds the fields of a Kubernetes API server audit event that rbac-wizard uses.
+type AuditEvent struct {
+	AuditID                  string            `json:"auditID"`
+	Stage                    string            `json:"stage"`
+	RequestURI               string            `json:"requestURI"`
+	Verb                     string            `json:"verb"`
+	User                     AuditUser         `json:"user"`
+	ImpersonatedUser         *AuditUser        `json:"impersonatedUser,omitempty"`
+	ObjectRef                *AuditObjectRef   `json:"objectRef,omitempty"`
+	ResponseStatus           *AuditStatus      `json:"responseStatus,omitempty"`
+	RequestReceivedTimestamp time.Time         `json:"requestReceivedTimestamp"`
+	Annotations              map[string]string `json:"annotations,omitempty"`
+}
+
+type AuditUser struct {
+	Username string   `json:"username"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+type AuditObjectRef struct {
+	Resource    string `json:"resource,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+	APIGroup    string `json:"apiGroup,omitempty"`
+	APIVersion  string `json:"apiVersion,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+}
+
+type AuditStatus struct {
+	Code int `json:"code"`
+}
+
+// AuditFilter selects the audit events of a time window, either end is open when it is zero.
+type AuditFilter struct {
+	Since time.Time
+	Until time.Time
+}
+
+// Includes reports whether the request of the event was received in the window of the filter.
+func (f AuditFilter) Includes(event AuditEvent) bool {
+	if !f.Since.IsZero() && event.RequestReceivedTimestamp.Before(f.Since) {
+		return false
+	}
+	return f.Until.IsZero() || !event.RequestReceivedTimestamp.After(f.Until)
+}
+
+// LoadAuditEvents reads the audit events of JSON lines audit logs, which can be gzipped.
+// The files of directories are read recursively. Only the events of completed requests
+// in the window of the filter are returned.
+func LoadAuditEvents(paths []string, filter AuditFilter) ([]AuditEvent, error) {
+	events := []AuditEvent{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			if events, err = readAuditLog(events, path, filter); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+				return nil
+			}
+			events, err = readAuditLog(events, p, filter)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return events, nil
+}
+
+func readAuditLog(events []AuditEvent, path string, filter AuditFilter) ([]AuditEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		content := strings.TrimSpace(scanner.Text())
+		if content == "" {
+			continue
+		}
+
+		var event AuditEvent
+		if err := json.Unmarshal([]byte(content), &event); err != nil {
+			return nil, fmt.Errorf("failed to decode audit event at %s:%d: %w", path, line, err)
+		}
+		if event.Stage != "" && event.Stage != auditStageResponseComplete {
+			continue
+		}
+		if filter.Includes(event) {
+			events = append(events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return events, nil
+}
+
+// Identity returns the identity the request was authorized as, which is the impersonated user if any.
+func (e AuditEvent) Identity() Identity {
+	user := e.User
+	if e.ImpersonatedUser != nil {
+		user = *e.ImpersonatedUser
+	}
+	return Identity{User: user.Username, Groups: user.Groups}
+}
+
+// Subject returns the binding subject of the user of the request.
+func (e AuditEvent) Subject() v1.Subject {
+	return subjectForUser(e.Identity().User)
+}
+
+// Denied reports whether the request was rejected by authentication or authorization.
+func (e AuditEvent) Denied() bool {
+	if e.Annotations[auditDecisionAnnotation] == "forbid" {
+		return true
+	}
+	return e.ResponseStatus != nil && (e.ResponseStatus.Code == 401 || e.ResponseStatus.Code == 403)
+}
+
+// IsResourceRequest reports whether the request is on an API resource rather than a non-resource URL.
+func (e AuditEvent) IsResourceRequest() bool {
+	return e.ObjectRef != nil && e.ObjectRef.Resource != ""
+}
+
+// Attributes returns the attributes of a resource request.
+func (e AuditEvent) Attributes() ResourceAttributes {
+	if !e.IsResourceRequest() {
+		return ResourceAttributes{Verb: e.Verb}
+	}
+	return ResourceAttributes{
+		Verb:        e.Verb,
+		APIGroup:    e.ObjectRef.APIGroup,
+		Resource:    e.ObjectRef.Resource,
+		Subresource: e.ObjectRef.Subresource,
+		Name:        e.ObjectRef.Name,
+		Namespace:   e.ObjectRef.Namespace,
+	}
+}
+
+// Request returns the request of the event to authorize.
+func (e AuditEvent) Request() Request {
+	request := Request{User: e.Identity(), ResourceAttributes: e.Attributes()}
+	if !e.IsResourceRequest() {
+		request.Path = e.Path()
+	}
+	return request
+}
+
+// Path returns the path of the request URI without its query.
+func (e AuditEvent) Path() string {
+	path, _, _ := strings.Cut(e.RequestURI, "?")
+	return path
+}
+
+// subjectForUser returns the binding subject of a user name, which is a service account
+// for the names of service account tokens.
+func subjectForUser(user string) v1.Subject {
+	if rest, ok := strings.CutPrefix(user, ServiceAccountPrefix); ok {
+		if namespace, name, ok := strings.Cut(rest, ":"); ok {
+			return v1.Subject{Kind: v1.ServiceAccountKind, Namespace: namespace, Name: name}
+		}
+	}
+	return v1.Subject{Kind: v1.UserKind, APIGroup: v1.GroupName, Name: user}
+}
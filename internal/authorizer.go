@@ -0,0 +1,160 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// Request is a request to authorize, on a resource or, when Path is set, on a non-resource URL.
+type Request struct {
+	User Identity `json:"user"`
+	ResourceAttributes
+	Path string `json:"path,omitempty"`
+}
+
+// Decision is the decision of the RBAC authorizer on a request, with the grant that allowed it.
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+	// Binding, RoleRef, Subject and Rule are those of the grant that allowed the request
+	Binding        *BindingRef    `json:"binding,omitempty"`
+	RoleRef        *v1.RoleRef    `json:"roleRef,omitempty"`
+	Subject        *v1.Subject    `json:"subject,omitempty"`
+	Rule           *v1.PolicyRule `json:"rule,omitempty"`
+	AggregatedFrom string         `json:"aggregatedFrom,omitempty"`
+}
+
+// IsResourceRequest reports whether the request is on a resource rather than a non-resource URL.
+func (r Request) IsResourceRequest() bool {
+	return r.Path == ""
+}
+
+// Authorize decides on a request like the Kubernetes RBAC authorizer does. The rules of ClusterRoleBindings
+// are visited first and then those of the RoleBindings of the namespace of the request, the request is allowed
+// by the first rule that matches. Non-resource URLs are only granted by ClusterRoleBindings, and requests
+// are never denied explicitly as RBAC only grants permissions.
+func Authorize(bindings *Bindings, roles *Roles, request Request) Decision {
+	for _, grant := range ResolveGrants(bindings, roles) {
+		if !grant.Allows(request) {
+			continue
+		}
+		subject, ok := grant.subjectOf(request.User)
+		if !ok {
+			continue
+		}
+
+		return Decision{
+			Allowed: true,
+			Reason: fmt.Sprintf("RBAC: allowed by %s of %s %q to %s", bindingReason(grant.Binding),
+				grant.RoleRef.Kind, grant.RoleRef.Name, subjectReason(subject, grant.Binding.Namespace)),
+			Binding:        &grant.Binding,
+			RoleRef:        &grant.RoleRef,
+			Subject:        &subject,
+			Rule:           &grant.Rule,
+			AggregatedFrom: grant.AggregatedFrom,
+		}
+	}
+
+	return Decision{Reason: "no RBAC policy matched"}
+}
+
+// Allows reports whether the rule of the grant allows the request, regardless of its user.
+func (g Grant) Allows(request Request) bool {
+	if request.IsResourceRequest() {
+		// Requests on the "*" API group are only allowed by rules of every API group, unlike who-can queries
+		if request.APIGroup == v1.APIGroupAll && !contains(g.Rule.APIGroups, v1.APIGroupAll) {
+			return false
+		}
+		return g.Matches(request.ResourceAttributes)
+	}
+	// Non-resource URLs are only granted by ClusterRoleBindings
+	return g.Namespace == "" && NonResourceRuleAllows(g.Rule, request.Verb, request.Path)
+}
+
+// subjectOf returns the first subject of the grant that refers to the identity.
+func (g Grant) subjectOf(id Identity) (v1.Subject, bool) {
+	for _, subject := range g.Subjects {
+		if id.Matches(subject, g.Binding.Namespace) {
+			return subject, true
+		}
+	}
+	return v1.Subject{}, false
+}
+
+// subjectReason returns how the RBAC authorizer names a subject in the reason of its decisions.
+func subjectReason(subject v1.Subject, namespace string) string {
+	if subject.Kind == v1.ServiceAccountKind {
+		if subject.Namespace != "" {
+			namespace = subject.Namespace
+		}
+		return fmt.Sprintf("%s %q", subject.Kind, subject.Name+"/"+namespace)
+	}
+	return fmt.Sprintf("%s %q", subject.Kind, subject.Name)
+}
+
+// IdentityForUser returns the identity of a user name as given to kubectl --as, which authenticates as a
+// service account for names of the form "system:serviceaccount:NAMESPACE:NAME", and has the extra groups.
+func IdentityForUser(user string, groups []string) Identity {
+	id := IdentityFor(subjectForUser(user))
+	for _, group := range groups {
+		if !contains(id.Groups, group) {
+			id.Groups = append(id.Groups, group)
+		}
+	}
+	return id
+}
+
+// resourceGroups maps the resources of the kinds of the client scheme to their API groups.
+var resourceGroups = func() map[string][]string {
+	groups := map[string][]string{}
+	for gvk := range scheme.Scheme.AllKnownTypes() {
+		if gvk.Version == "__internal" || strings.HasSuffix(gvk.Kind, "List") || strings.HasSuffix(gvk.Kind, "Options") {
+			continue
+		}
+		resource, _ := meta.UnsafeGuessKindToResource(gvk)
+		if !contains(groups[resource.Resource], gvk.Group) {
+			groups[resource.Resource] = append(groups[resource.Resource], gvk.Group)
+		}
+	}
+	for resource := range groups {
+		sort.Strings(groups[resource])
+	}
+	return groups
+}()
+
+// APIGroupOf returns the API group of a built-in resource, preferring the core group like kubectl does
+// when several groups serve a resource of the same name.
+func APIGroupOf(resource string) (string, bool) {
+	groups := resourceGroups[resource]
+	if len(groups) == 0 {
+		return "", false
+	}
+	return groups[0], true
+}
@@ -27,6 +27,7 @@ import (
 	"fmt"
 
 	"gopkg.in/yaml.v2"
+	v1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
@@ -41,6 +42,10 @@ const (
 )
 
 func (app App) GetBindings() (*Bindings, error) {
+	if app.Store != nil {
+		return app.Store.Bindings(), nil
+	}
+
 	clientset := app.KubeClient
 
 	crbs, err := clientset.RbacV1().ClusterRoleBindings().List(context.TODO(), metav1.ListOptions{})
@@ -61,40 +66,61 @@ func (app App) GetBindings() (*Bindings, error) {
 	}, nil
 }
 
-func GenerateData(bindings *Bindings) []Data {
+func GenerateData(bindings *Bindings, cluster string) []Data {
 	var data []Data
-	var i int
 
 	for _, crb := range bindings.ClusterRoleBindings.Items {
-		crb.ManagedFields = nil
-
-		data = append(data, Data{
-			Name:     crb.Name,
-			Id:       i,
-			Kind:     ClusterRoleBindingKind,
-			Subjects: crb.Subjects,
-			RoleRef:  crb.RoleRef,
-			Raw:      yamlParser(&crb, ClusterRoleBindingKind, ClusterRoleBindingAPIVersion),
-		})
-		i++
+		data = append(data, clusterRoleBindingData(crb, cluster))
 	}
 
 	for _, rb := range bindings.RoleBindings.Items {
-		rb.ManagedFields = nil
-		data = append(data, Data{
-			Name:     rb.Name,
-			Id:       i,
-			Kind:     RoleBindingKind,
-			Subjects: rb.Subjects,
-			RoleRef:  rb.RoleRef,
-			Raw:      yamlParser(&rb, RoleBindingKind, RoleBindingAPIVersion),
-		})
-		i++
+		data = append(data, roleBindingData(rb, cluster))
 	}
 
 	return data
 }
 
+func clusterRoleBindingData(crb v1.ClusterRoleBinding, cluster string) Data {
+	crb.ManagedFields = nil
+
+	return Data{
+		Id:         NodeID(cluster, ClusterRoleBindingKind, "", crb.Name),
+		Name:       crb.Name,
+		Cluster:    cluster,
+		Kind:       ClusterRoleBindingKind,
+		Subjects:   crb.Subjects,
+		RoleRef:    crb.RoleRef,
+		SubjectIds: subjectIDs(cluster, crb.Subjects, ""),
+		RoleRefId:  RoleRefID(cluster, crb.RoleRef, ""),
+		Raw:        yamlParser(&crb, ClusterRoleBindingKind, ClusterRoleBindingAPIVersion),
+	}
+}
+
+func roleBindingData(rb v1.RoleBinding, cluster string) Data {
+	rb.ManagedFields = nil
+
+	return Data{
+		Id:         NodeID(cluster, RoleBindingKind, rb.Namespace, rb.Name),
+		Name:       rb.Name,
+		Namespace:  rb.Namespace,
+		Cluster:    cluster,
+		Kind:       RoleBindingKind,
+		Subjects:   rb.Subjects,
+		RoleRef:    rb.RoleRef,
+		SubjectIds: subjectIDs(cluster, rb.Subjects, rb.Namespace),
+		RoleRefId:  RoleRefID(cluster, rb.RoleRef, rb.Namespace),
+		Raw:        yamlParser(&rb, RoleBindingKind, RoleBindingAPIVersion),
+	}
+}
+
+func subjectIDs(cluster string, subjects []v1.Subject, namespace string) []string {
+	ids := make([]string, 0, len(subjects))
+	for _, subject := range subjects {
+		ids = append(ids, SubjectID(cluster, subject, namespace))
+	}
+	return ids
+}
+
 func yamlParser(obj runtime.Object, kind string, apiVersion string) string {
 	// Convert the object to YAML
 	s := json.NewSerializerWithOptions(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme, json.SerializerOptions{Yaml: true, Pretty: true})
@@ -24,41 +24,121 @@ package internal
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 // GetClientset Creates a new clientset for the kubernetes
 func GetClientset() (*kubernetes.Clientset, error) {
-	var config *rest.Config
+	config, err := getConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create and store the clientset
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %v", err)
+	}
+
+	return clientset, nil
+}
+
+// GetClientsetForContext creates a new clientset for the given context of the kubeconfig
+func GetClientsetForContext(context string) (*kubernetes.Clientset, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		rules,
+		&clientcmd.ConfigOverrides{CurrentContext: context},
+	).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build config for context %s from kubeconfig %s: %v", context, kubeconfigPaths(rules), err)
+	}
 
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %v", err)
+	}
+
+	return clientset, nil
+}
+
+// GetContexts returns the names of every context of the kubeconfig, merged from every file of KUBECONFIG
+func GetContexts() ([]string, error) {
+	rawConfig, err := loadKubeconfig()
+	if err != nil {
+		return nil, err
+	}
+
+	contexts := make([]string, 0, len(rawConfig.Contexts))
+	for name := range rawConfig.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+
+	return contexts, nil
+}
+
+// GetClusterInfo returns the name of the kubeconfig context and the server it points to, the
+// current context is used when the given one is empty. The context name is empty when running in a cluster.
+func GetClusterInfo(context string) (string, string, error) {
+	if context == "" {
+		if config, err := rest.InClusterConfig(); err == nil {
+			return "", config.Host, nil
+		}
+	}
+
+	rawConfig, err := loadKubeconfig()
+	if err != nil {
+		return "", "", err
+	}
+
+	if context == "" {
+		context = rawConfig.CurrentContext
+	}
+
+	var server string
+	if ctx, ok := rawConfig.Contexts[context]; ok {
+		if cluster, ok := rawConfig.Clusters[ctx.Cluster]; ok {
+			server = cluster.Server
+		}
+	}
+
+	return context, server, nil
+}
+
+func getConfig() (*rest.Config, error) {
 	// First try to use the in-cluster configuration
 	config, err := rest.InClusterConfig()
 	if err != nil {
 		// Fallback to kubeconfig
-		var kubeconfig string
-		if kc := os.Getenv("KUBECONFIG"); kc != "" {
-			kubeconfig = kc
-		} else {
-			kubeconfig = filepath.Join(homedir.HomeDir(), ".kube", "config")
-		}
-
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
 		if err != nil {
-			return nil, fmt.Errorf("failed to build config from kubeconfig path %s: %v", kubeconfig, err)
+			return nil, fmt.Errorf("failed to build config from kubeconfig %s: %v", kubeconfigPaths(rules), err)
 		}
 	}
 
-	// Create and store the clientset
-	clientset, err := kubernetes.NewForConfig(config)
+	return config, nil
+}
+
+// loadKubeconfig merges the kubeconfig files the way kubectl does, from the paths of KUBECONFIG
+// or from ~/.kube/config when it is not set.
+func loadKubeconfig() (*clientcmdapi.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rawConfig, err := rules.Load()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create clientset: %v", err)
+		return nil, fmt.Errorf("failed to load kubeconfig %s: %v", kubeconfigPaths(rules), err)
 	}
+	return rawConfig, nil
+}
 
-	return clientset, nil
+func kubeconfigPaths(rules *clientcmd.ClientConfigLoadingRules) string {
+	return strings.Join(rules.GetLoadingPrecedence(), string(filepath.ListSeparator))
 }
@@ -0,0 +1,345 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+)
+
+// record re-records the statuses of the reviews of the conformance scenarios from the cluster of the
+// current kubeconfig context, whose RBAC authorizer is the ground truth of the local one:
+//
+//	go test ./internal -run TestConformance -record
+var record = flag.Bool("record", false, "record the reviews of the conformance scenarios from the current cluster")
+
+// TestConformance compares the decisions of the local authorizer with the statuses of SubjectAccessReviews.
+// Each directory of testdata/conformance is a scenario with the RBAC objects of objects.yaml and the reviews
+// of reviews.yaml. Allowed reviews must also give the same reason, which names the binding, role and subject
+// that allowed the request, so every allowed request is granted by a single binding of its scenario.
+//
+// The statuses of the reviews are recorded from the RBAC authorizer of a Kubernetes v1.30.0 API server
+// with -record.
+func TestConformance(t *testing.T) {
+	scenarios, err := filepath.Glob(filepath.Join("testdata", "conformance", "*", "objects.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, objectsFile := range scenarios {
+		dir := filepath.Dir(objectsFile)
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			objects := readConformanceObjects(t, objectsFile)
+			reviews := readConformanceReviews(t, filepath.Join(dir, "reviews.yaml"))
+			if *record {
+				reviews = recordConformanceReviews(t, objects, reviews)
+				writeConformanceReviews(t, filepath.Join(dir, "reviews.yaml"), reviews)
+			}
+
+			app := App{KubeClient: fake.NewSimpleClientset(objects...)}
+			bindings, err := app.GetBindings()
+			if err != nil {
+				t.Fatal(err)
+			}
+			roles, err := app.GetRoles()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, review := range reviews {
+				decision := Authorize(bindings, roles, conformanceRequest(review.Spec))
+				if decision.Allowed != review.Status.Allowed {
+					t.Errorf("review %d %s: allowed = %t, want %t (%s)", i, describeReview(review.Spec),
+						decision.Allowed, review.Status.Allowed, decision.Reason)
+					continue
+				}
+				if review.Status.Allowed && review.Status.Reason != "" && decision.Reason != review.Status.Reason {
+					t.Errorf("review %d %s: reason = %q, want %q", i, describeReview(review.Spec),
+						decision.Reason, review.Status.Reason)
+				}
+			}
+		})
+	}
+}
+
+// conformanceRequest returns the request of a review, with the groups of the review only, as the
+// API server does not add the groups of the user to SubjectAccessReviews.
+func conformanceRequest(spec authorizationv1.SubjectAccessReviewSpec) Request {
+	request := Request{User: Identity{User: spec.User, Groups: spec.Groups}}
+	if attrs := spec.NonResourceAttributes; attrs != nil {
+		request.Verb = attrs.Verb
+		request.Path = attrs.Path
+		return request
+	}
+	if attrs := spec.ResourceAttributes; attrs != nil {
+		request.ResourceAttributes = ResourceAttributes{
+			Verb:        attrs.Verb,
+			APIGroup:    attrs.Group,
+			Resource:    attrs.Resource,
+			Subresource: attrs.Subresource,
+			Name:        attrs.Name,
+			Namespace:   attrs.Namespace,
+		}
+	}
+	return request
+}
+
+func describeReview(spec authorizationv1.SubjectAccessReviewSpec) string {
+	if attrs := spec.NonResourceAttributes; attrs != nil {
+		return spec.User + " " + attrs.Verb + " " + attrs.Path
+	}
+	attrs := spec.ResourceAttributes
+	if attrs == nil {
+		return spec.User
+	}
+	resource := attrs.Resource
+	if attrs.Group != "" {
+		resource += "." + attrs.Group
+	}
+	if attrs.Subresource != "" {
+		resource += "/" + attrs.Subresource
+	}
+	if attrs.Name != "" {
+		resource += " " + attrs.Name
+	}
+	if attrs.Namespace != "" {
+		resource += " in " + attrs.Namespace
+	}
+	return spec.User + " " + attrs.Verb + " " + resource
+}
+
+func readConformanceObjects(t *testing.T, path string) []runtime.Object {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects, err := DecodeObjects(content)
+	if err != nil {
+		t.Fatalf("decoding %s: %v", path, err)
+	}
+	return objects
+}
+
+func readConformanceReviews(t *testing.T, path string) []authorizationv1.SubjectAccessReview {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reviews []authorizationv1.SubjectAccessReview
+	for _, document := range bytes.Split(content, []byte("\n---\n")) {
+		if len(bytes.TrimSpace(document)) == 0 {
+			continue
+		}
+		var review authorizationv1.SubjectAccessReview
+		if err := yaml.UnmarshalStrict(document, &review); err != nil {
+			t.Fatalf("decoding %s: %v", path, err)
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews
+}
+
+func writeConformanceReviews(t *testing.T, path string, reviews []authorizationv1.SubjectAccessReview) {
+	t.Helper()
+	var content bytes.Buffer
+	for i, review := range reviews {
+		document, err := yaml.Marshal(map[string]interface{}{
+			"apiVersion": "authorization.k8s.io/v1",
+			"kind":       "SubjectAccessReview",
+			"spec":       review.Spec,
+			"status":     review.Status,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 {
+			content.WriteString("---\n")
+		}
+		content.Write(document)
+	}
+	if err := os.WriteFile(path, content.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// recordConformanceReviews creates the objects of a scenario in the current cluster, and returns the
+// reviews with the statuses given by its API server. The namespaces and cluster scoped objects of the
+// scenario must not exist yet, so that recording never changes objects it did not create, and they are
+// deleted once the scenario is recorded.
+func recordConformanceReviews(t *testing.T, objects []runtime.Object, reviews []authorizationv1.SubjectAccessReview) []authorizationv1.SubjectAccessReview {
+	t.Helper()
+	client, err := GetClientset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.TODO()
+
+	namespaces := map[string]bool{}
+	for _, obj := range objects {
+		if err := createConformanceObject(ctx, t, client, obj, namespaces); err != nil {
+			t.Fatalf("creating %T: %v", obj, err)
+		}
+	}
+	waitForAggregation(ctx, t, client, objects)
+
+	recorded := make([]authorizationv1.SubjectAccessReview, 0, len(reviews))
+	for _, review := range reviews {
+		review.Status = settledConformanceReview(ctx, t, client, review.Spec)
+		recorded = append(recorded, review)
+	}
+	return recorded
+}
+
+// settledConformanceReview returns the status of a review once the RBAC authorizer of the API server has
+// observed the objects of the scenario, which is when the same status is returned for a few polls in a row.
+func settledConformanceReview(ctx context.Context, t *testing.T, client kubernetes.Interface, spec authorizationv1.SubjectAccessReviewSpec) authorizationv1.SubjectAccessReviewStatus {
+	t.Helper()
+	const settledPolls = 4
+
+	var status authorizationv1.SubjectAccessReviewStatus
+	same := 0
+	err := wait.PollUntilContextTimeout(ctx, 250*time.Millisecond, 30*time.Second, true, func(ctx context.Context) (bool, error) {
+		result, err := client.AuthorizationV1().SubjectAccessReviews().Create(ctx,
+			&authorizationv1.SubjectAccessReview{Spec: spec}, metav1.CreateOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		current := authorizationv1.SubjectAccessReviewStatus{Allowed: result.Status.Allowed, Reason: result.Status.Reason}
+		if current == status {
+			same++
+		} else {
+			status, same = current, 1
+		}
+		return same >= settledPolls, nil
+	})
+	if err != nil {
+		t.Fatalf("reviewing %s: %v", describeReview(spec), err)
+	}
+	return status
+}
+
+// waitForAggregation waits for the aggregation controller to set the rules of the aggregated ClusterRoles
+// of the scenario to the rules they resolve to locally.
+func waitForAggregation(ctx context.Context, t *testing.T, client kubernetes.Interface, objects []runtime.Object) {
+	t.Helper()
+	var clusterRoles []v1.ClusterRole
+	for _, obj := range objects {
+		if role, ok := obj.(*v1.ClusterRole); ok {
+			clusterRoles = append(clusterRoles, *role)
+		}
+	}
+
+	resolved := AggregateClusterRoles(clusterRoles)
+	for _, role := range clusterRoles {
+		if role.AggregationRule == nil {
+			continue
+		}
+		var rules []v1.PolicyRule
+		for _, rule := range resolved[role.Name] {
+			rules = append(rules, rule.Rule)
+		}
+		var current []v1.PolicyRule
+		err := wait.PollUntilContextTimeout(ctx, 250*time.Millisecond, 30*time.Second, true, func(ctx context.Context) (bool, error) {
+			aggregated, err := client.RbacV1().ClusterRoles().Get(ctx, role.Name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			current = aggregated.Rules
+			added, removed := diffRules(rules, current)
+			return len(added) == 0 && len(removed) == 0, nil
+		})
+		if err != nil {
+			t.Fatalf("waiting for the aggregation of ClusterRole %s to %v, has %v: %v", role.Name, rules, current, err)
+		}
+	}
+}
+
+// createConformanceObject creates an RBAC object, and its namespace unless it is one of the namespaces
+// already created for the scenario, and deletes them when the test ends. It fails when the object or its
+// namespace already exist.
+func createConformanceObject(ctx context.Context, t *testing.T, client kubernetes.Interface, obj runtime.Object, namespaces map[string]bool) error {
+	t.Helper()
+	if namespaced, ok := obj.(metav1.Object); ok && namespaced.GetNamespace() != "" && !namespaces[namespaced.GetNamespace()] {
+		name := namespaced.GetNamespace()
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if _, err := client.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+		namespaces[name] = true
+		t.Cleanup(func() {
+			if err := client.CoreV1().Namespaces().Delete(context.TODO(), name, metav1.DeleteOptions{}); err != nil {
+				t.Errorf("deleting Namespace %s: %v", name, err)
+			}
+		})
+	}
+
+	rbac := client.RbacV1()
+	var cleanup func() error
+	switch o := obj.(type) {
+	case *v1.ClusterRole:
+		if _, err := rbac.ClusterRoles().Create(ctx, o, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+		cleanup = func() error { return rbac.ClusterRoles().Delete(context.TODO(), o.Name, metav1.DeleteOptions{}) }
+	case *v1.ClusterRoleBinding:
+		if _, err := rbac.ClusterRoleBindings().Create(ctx, o, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+		cleanup = func() error { return rbac.ClusterRoleBindings().Delete(context.TODO(), o.Name, metav1.DeleteOptions{}) }
+	case *v1.Role:
+		_, err := rbac.Roles(o.Namespace).Create(ctx, o, metav1.CreateOptions{})
+		return err
+	case *v1.RoleBinding:
+		_, err := rbac.RoleBindings(o.Namespace).Create(ctx, o, metav1.CreateOptions{})
+		return err
+	}
+
+	// Roles and RoleBindings are deleted with their namespace
+	if cleanup != nil {
+		t.Cleanup(func() {
+			if err := cleanup(); err != nil {
+				t.Errorf("deleting %T: %v", obj, err)
+			}
+		})
+	}
+	return nil
+}
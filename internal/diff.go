@@ -0,0 +1,425 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/rbac/v1"
+)
+
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// ObjectChange is a binding or role that was added, removed or modified between two states.
+type ObjectChange struct {
+	Type            string          `json:"type"`
+	Kind            string          `json:"kind"`
+	Namespace       string          `json:"namespace,omitempty"`
+	Name            string          `json:"name"`
+	SubjectsAdded   []v1.Subject    `json:"subjectsAdded,omitempty"`
+	SubjectsRemoved []v1.Subject    `json:"subjectsRemoved,omitempty"`
+	OldRoleRef      *v1.RoleRef     `json:"oldRoleRef,omitempty"`
+	NewRoleRef      *v1.RoleRef     `json:"newRoleRef,omitempty"`
+	RulesAdded      []v1.PolicyRule `json:"rulesAdded,omitempty"`
+	RulesRemoved    []v1.PolicyRule `json:"rulesRemoved,omitempty"`
+}
+
+// PermissionChange is the change of the effective permissions of a subject between two states.
+type PermissionChange struct {
+	Subject v1.Subject   `json:"subject"`
+	Added   []Permission `json:"added,omitempty"`
+	Removed []Permission `json:"removed,omitempty"`
+}
+
+type Diff struct {
+	Bindings    []ObjectChange     `json:"bindings"`
+	Roles       []ObjectChange     `json:"roles"`
+	Permissions []PermissionChange `json:"permissions"`
+}
+
+// Empty reports whether there are no changes.
+func (d *Diff) Empty() bool {
+	return len(d.Bindings) == 0 && len(d.Roles) == 0 && len(d.Permissions) == 0
+}
+
+// DiffStores compares the bindings, roles and the effective permissions of every subject of two states.
+func DiffStores(before *Store, after *Store) *Diff {
+	return diffStates(before.Bindings(), before.Roles(), after.Bindings(), after.Roles())
+}
+
+func diffStates(oldBindings *Bindings, oldRoles *Roles, newBindings *Bindings, newRoles *Roles) *Diff {
+	return &Diff{
+		Bindings:    diffBindings(oldBindings, newBindings),
+		Roles:       diffRoles(oldRoles, newRoles),
+		Permissions: DiffPermissions(oldBindings, oldRoles, newBindings, newRoles),
+	}
+}
+
+// DiffPermissions compares the effective permissions of every subject bound in either state.
+func DiffPermissions(oldBindings *Bindings, oldRoles *Roles, newBindings *Bindings, newRoles *Roles) []PermissionChange {
+	changes := []PermissionChange{}
+	oldIndex := indexPermissions(oldBindings, oldRoles)
+	newIndex := indexPermissions(newBindings, newRoles)
+
+	for _, subject := range boundSubjects(oldBindings, newBindings) {
+		id := IdentityFor(subject)
+		added, removed := diffPermissionSets(oldIndex.permissions(id), newIndex.permissions(id))
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+		changes = append(changes, PermissionChange{
+			Subject: subject,
+			Added:   added,
+			Removed: removed,
+		})
+	}
+
+	return changes
+}
+
+// permissionIndex maps the users and groups bound in a state to the permissions granted to them,
+// with service accounts indexed by the user name they authenticate as.
+type permissionIndex map[string]map[Permission]struct{}
+
+func indexPermissions(bindings *Bindings, roles *Roles) permissionIndex {
+	index := permissionIndex{}
+	for _, grant := range ResolveGrants(bindings, roles) {
+		permissions := expandRule(grant.Rule, grant.Namespace)
+		for _, subject := range grant.Subjects {
+			key, ok := identityKey(subject, grant.Binding.Namespace)
+			if !ok {
+				continue
+			}
+			if index[key] == nil {
+				index[key] = map[Permission]struct{}{}
+			}
+			for _, p := range permissions {
+				index[key][p] = struct{}{}
+			}
+		}
+	}
+	return index
+}
+
+// permissions returns the sorted permissions granted to the identity, the same as ResolveIdentityPermissions.
+func (index permissionIndex) permissions(id Identity) []Permission {
+	keys := make([]string, 0, len(id.Groups)+1)
+	if id.User != "" {
+		keys = append(keys, v1.UserKind+"/"+id.User)
+	}
+	for _, group := range id.Groups {
+		keys = append(keys, v1.GroupKind+"/"+group)
+	}
+
+	seen := map[Permission]struct{}{}
+	for _, key := range keys {
+		for p := range index[key] {
+			seen[p] = struct{}{}
+		}
+	}
+
+	permissions := make([]Permission, 0, len(seen))
+	for p := range seen {
+		permissions = append(permissions, p)
+	}
+	sortPermissions(permissions)
+	return permissions
+}
+
+// identityKey returns the key of the user or group a binding subject in the given namespace refers to,
+// following Identity.Matches.
+func identityKey(subject v1.Subject, namespace string) (string, bool) {
+	switch subject.Kind {
+	case v1.UserKind:
+		return v1.UserKind + "/" + subject.Name, subject.Name != ""
+	case v1.GroupKind:
+		return v1.GroupKind + "/" + subject.Name, true
+	case v1.ServiceAccountKind:
+		if subject.Namespace != "" {
+			namespace = subject.Namespace
+		}
+		if namespace == "" {
+			return "", false
+		}
+		return v1.UserKind + "/" + ServiceAccountPrefix + namespace + ":" + subject.Name, true
+	}
+	return "", false
+}
+
+// boundSubjects returns every distinct subject of the bindings, with the namespace of
+// service accounts defaulted to the namespace of their RoleBinding.
+func boundSubjects(all ...*Bindings) []v1.Subject {
+	seen := map[string]v1.Subject{}
+	add := func(subjects []v1.Subject, namespace string) {
+		for _, subject := range subjects {
+			if subject.Kind == v1.ServiceAccountKind && subject.Namespace == "" {
+				subject.Namespace = namespace
+			}
+			subject.APIGroup = ""
+			seen[subjectKey(subject)] = subject
+		}
+	}
+
+	for _, bindings := range all {
+		if bindings == nil {
+			continue
+		}
+		if bindings.ClusterRoleBindings != nil {
+			for _, crb := range bindings.ClusterRoleBindings.Items {
+				add(crb.Subjects, "")
+			}
+		}
+		if bindings.RoleBindings != nil {
+			for _, rb := range bindings.RoleBindings.Items {
+				add(rb.Subjects, rb.Namespace)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	subjects := make([]v1.Subject, 0, len(keys))
+	for _, key := range keys {
+		subjects = append(subjects, seen[key])
+	}
+	return subjects
+}
+
+func subjectKey(subject v1.Subject) string {
+	return subject.Kind + "/" + subject.Namespace + "/" + subject.Name
+}
+
+func diffPermissionSets(before []Permission, after []Permission) (added []Permission, removed []Permission) {
+	beforeSet := map[Permission]struct{}{}
+	for _, p := range before {
+		beforeSet[p] = struct{}{}
+	}
+	afterSet := map[Permission]struct{}{}
+	for _, p := range after {
+		afterSet[p] = struct{}{}
+		if _, ok := beforeSet[p]; !ok {
+			added = append(added, p)
+		}
+	}
+	for _, p := range before {
+		if _, ok := afterSet[p]; !ok {
+			removed = append(removed, p)
+		}
+	}
+	return added, removed
+}
+
+func diffBindings(before *Bindings, after *Bindings) []ObjectChange {
+	type binding struct {
+		kind      string
+		namespace string
+		name      string
+		subjects  []v1.Subject
+		roleRef   v1.RoleRef
+	}
+
+	index := func(bindings *Bindings) map[string]binding {
+		m := map[string]binding{}
+		for _, crb := range bindings.ClusterRoleBindings.Items {
+			m[ClusterRoleBindingKind+"/"+crb.Name] = binding{ClusterRoleBindingKind, "", crb.Name, crb.Subjects, crb.RoleRef}
+		}
+		for _, rb := range bindings.RoleBindings.Items {
+			m[RoleBindingKind+"/"+rb.Namespace+"/"+rb.Name] = binding{RoleBindingKind, rb.Namespace, rb.Name, rb.Subjects, rb.RoleRef}
+		}
+		return m
+	}
+
+	oldIndex, newIndex := index(before), index(after)
+	changes := []ObjectChange{}
+
+	for _, key := range unionKeys(oldIndex, newIndex) {
+		o, inOld := oldIndex[key]
+		n, inNew := newIndex[key]
+
+		switch {
+		case !inOld:
+			roleRef := n.roleRef
+			changes = append(changes, ObjectChange{Type: ChangeAdded, Kind: n.kind, Namespace: n.namespace, Name: n.name, SubjectsAdded: n.subjects, NewRoleRef: &roleRef})
+		case !inNew:
+			roleRef := o.roleRef
+			changes = append(changes, ObjectChange{Type: ChangeRemoved, Kind: o.kind, Namespace: o.namespace, Name: o.name, SubjectsRemoved: o.subjects, OldRoleRef: &roleRef})
+		default:
+			change := ObjectChange{Type: ChangeModified, Kind: n.kind, Namespace: n.namespace, Name: n.name}
+			change.SubjectsAdded, change.SubjectsRemoved = diffSubjects(o.subjects, n.subjects)
+			if !reflect.DeepEqual(o.roleRef, n.roleRef) {
+				oldRoleRef, newRoleRef := o.roleRef, n.roleRef
+				change.OldRoleRef, change.NewRoleRef = &oldRoleRef, &newRoleRef
+			}
+			if len(change.SubjectsAdded) > 0 || len(change.SubjectsRemoved) > 0 || change.NewRoleRef != nil {
+				changes = append(changes, change)
+			}
+		}
+	}
+
+	return changes
+}
+
+func diffRoles(before *Roles, after *Roles) []ObjectChange {
+	type role struct {
+		kind      string
+		namespace string
+		name      string
+		rules     []v1.PolicyRule
+	}
+
+	// Aggregated ClusterRoles are compared by their resolved rules, so that changes to their
+	// component ClusterRoles show up on them too
+	index := func(roles *Roles) map[string]role {
+		m := map[string]role{}
+		rules := newRoleIndex(roles)
+		for _, cr := range roles.ClusterRoles.Items {
+			resolved, _ := rules.rules(v1.RoleRef{Kind: ClusterRoleKind, Name: cr.Name}, "")
+			m[ClusterRoleKind+"/"+cr.Name] = role{ClusterRoleKind, "", cr.Name, resolved}
+		}
+		for _, r := range roles.Roles.Items {
+			m[RoleKind+"/"+r.Namespace+"/"+r.Name] = role{RoleKind, r.Namespace, r.Name, r.Rules}
+		}
+		return m
+	}
+
+	oldIndex, newIndex := index(before), index(after)
+	changes := []ObjectChange{}
+
+	for _, key := range unionKeys(oldIndex, newIndex) {
+		o, inOld := oldIndex[key]
+		n, inNew := newIndex[key]
+
+		switch {
+		case !inOld:
+			changes = append(changes, ObjectChange{Type: ChangeAdded, Kind: n.kind, Namespace: n.namespace, Name: n.name, RulesAdded: n.rules})
+		case !inNew:
+			changes = append(changes, ObjectChange{Type: ChangeRemoved, Kind: o.kind, Namespace: o.namespace, Name: o.name, RulesRemoved: o.rules})
+		default:
+			added, removed := diffRules(o.rules, n.rules)
+			if len(added) > 0 || len(removed) > 0 {
+				changes = append(changes, ObjectChange{Type: ChangeModified, Kind: n.kind, Namespace: n.namespace, Name: n.name, RulesAdded: added, RulesRemoved: removed})
+			}
+		}
+	}
+
+	return changes
+}
+
+func diffSubjects(before []v1.Subject, after []v1.Subject) (added []v1.Subject, removed []v1.Subject) {
+	oldSet := map[v1.Subject]struct{}{}
+	for _, s := range before {
+		oldSet[s] = struct{}{}
+	}
+	newSet := map[v1.Subject]struct{}{}
+	for _, s := range after {
+		newSet[s] = struct{}{}
+		if _, ok := oldSet[s]; !ok {
+			added = append(added, s)
+		}
+	}
+	for _, s := range before {
+		if _, ok := newSet[s]; !ok {
+			removed = append(removed, s)
+		}
+	}
+	return added, removed
+}
+
+func diffRules(before []v1.PolicyRule, after []v1.PolicyRule) (added []v1.PolicyRule, removed []v1.PolicyRule) {
+	oldSet := map[string]struct{}{}
+	for _, r := range before {
+		oldSet[ruleKey(r)] = struct{}{}
+	}
+	newSet := map[string]struct{}{}
+	for _, r := range after {
+		newSet[ruleKey(r)] = struct{}{}
+		if _, ok := oldSet[ruleKey(r)]; !ok {
+			added = append(added, r)
+		}
+	}
+	for _, r := range before {
+		if _, ok := newSet[ruleKey(r)]; !ok {
+			removed = append(removed, r)
+		}
+	}
+	return added, removed
+}
+
+// ruleKey returns a canonical representation of a rule to compare rules by value.
+func ruleKey(rule v1.PolicyRule) string {
+	key, _ := json.Marshal(rule)
+	return string(key)
+}
+
+func unionKeys[T any](a map[string]T, b map[string]T) []string {
+	seen := map[string]struct{}{}
+	for key := range a {
+		seen[key] = struct{}{}
+	}
+	for key := range b {
+		seen[key] = struct{}{}
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String renders the permission in a short human readable form.
+func (p Permission) String() string {
+	if p.NonResourceURL != "" {
+		return p.Verb + " " + p.NonResourceURL
+	}
+
+	var b strings.Builder
+	b.WriteString(p.Verb + " " + p.Resource)
+	if p.APIGroup != "" {
+		b.WriteString("." + p.APIGroup)
+	}
+	if p.Subresource != "" {
+		b.WriteString("/" + p.Subresource)
+	}
+	if p.ResourceName != "" {
+		b.WriteString(" " + p.ResourceName)
+	}
+	if p.Namespace != "" {
+		b.WriteString(" in " + p.Namespace)
+	} else {
+		b.WriteString(" cluster-wide")
+	}
+	return b.String()
+}
@@ -0,0 +1,493 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/rbac/v1"
+)
+
+const (
+	TechniqueClusterAdmin  = "cluster-admin"
+	TechniqueEscalate      = "escalate"
+	TechniqueBind          = "bind"
+	TechniqueImpersonate   = "impersonate"
+	TechniqueCreateToken   = "create-token"
+	TechniqueCreatePods    = "create-pods"
+	TechniquePatchBindings = "patch-bindings"
+	TechniqueApproveCSR    = "approve-csr"
+	TechniqueWebhooks      = "write-webhooks"
+)
+
+// maxEscalationHops bounds the number of subjects an escalation path goes through.
+const maxEscalationHops = 3
+
+// EscalationHop is a step of an escalation path, where a subject acts as another subject.
+type EscalationHop struct {
+	Technique string     `json:"technique"`
+	Namespace string     `json:"namespace,omitempty"`
+	Binding   BindingRef `json:"binding"`
+	// Subject is the subject acted as after the hop
+	Subject v1.Subject `json:"subject"`
+}
+
+// Escalation is a way for a subject to gain permissions it was not granted, either directly or by
+// acting as the subjects of its path first. Binding, Rule and Namespace are the grant of the
+// technique to the last subject of the path.
+type Escalation struct {
+	Cluster     string          `json:"cluster,omitempty"`
+	Subject     v1.Subject      `json:"subject"`
+	Path        []EscalationHop `json:"path"`
+	Technique   string          `json:"technique"`
+	Description string          `json:"description"`
+	Namespace   string          `json:"namespace,omitempty"`
+	Binding     BindingRef      `json:"binding"`
+	Rule        v1.PolicyRule   `json:"rule"`
+	// ResourceNames are the objects the technique is restricted to by the resourceNames of the rule
+	ResourceNames []string `json:"resourceNames,omitempty"`
+}
+
+// escalationCheck is a set of requests that let a subject gain permissions beyond its own.
+type escalationCheck struct {
+	technique   string
+	description string
+	requests    []ResourceAttributes
+}
+
+var escalationChecks = []escalationCheck{
+	{
+		technique:   TechniqueEscalate,
+		description: "can create or update roles with permissions it does not hold",
+		requests: []ResourceAttributes{
+			{Verb: "escalate", APIGroup: v1.GroupName, Resource: "roles"},
+			{Verb: "escalate", APIGroup: v1.GroupName, Resource: "clusterroles"},
+		},
+	},
+	{
+		technique:   TechniqueBind,
+		description: "can bind roles with permissions it does not hold",
+		requests: []ResourceAttributes{
+			{Verb: "bind", APIGroup: v1.GroupName, Resource: "roles"},
+			{Verb: "bind", APIGroup: v1.GroupName, Resource: "clusterroles"},
+		},
+	},
+	{
+		technique:   TechniquePatchBindings,
+		description: "can add subjects to existing bindings",
+		requests: []ResourceAttributes{
+			{Verb: "patch", APIGroup: v1.GroupName, Resource: "rolebindings"},
+			{Verb: "update", APIGroup: v1.GroupName, Resource: "rolebindings"},
+			{Verb: "patch", APIGroup: v1.GroupName, Resource: "clusterrolebindings"},
+			{Verb: "update", APIGroup: v1.GroupName, Resource: "clusterrolebindings"},
+		},
+	},
+	{
+		technique:   TechniqueApproveCSR,
+		description: "can approve certificate signing requests and issue client certificates for any user",
+		requests: []ResourceAttributes{
+			{Verb: "update", APIGroup: "certificates.k8s.io", Resource: "certificatesigningrequests", Subresource: "approval"},
+			{Verb: "patch", APIGroup: "certificates.k8s.io", Resource: "certificatesigningrequests", Subresource: "approval"},
+		},
+	},
+	{
+		technique:   TechniqueWebhooks,
+		description: "can intercept and mutate objects sent to the API server through admission webhooks",
+		requests: []ResourceAttributes{
+			{Verb: "create", APIGroup: "admissionregistration.k8s.io", Resource: "mutatingwebhookconfigurations"},
+			{Verb: "update", APIGroup: "admissionregistration.k8s.io", Resource: "mutatingwebhookconfigurations"},
+			{Verb: "patch", APIGroup: "admissionregistration.k8s.io", Resource: "mutatingwebhookconfigurations"},
+			{Verb: "create", APIGroup: "admissionregistration.k8s.io", Resource: "validatingwebhookconfigurations"},
+			{Verb: "update", APIGroup: "admissionregistration.k8s.io", Resource: "validatingwebhookconfigurations"},
+			{Verb: "patch", APIGroup: "admissionregistration.k8s.io", Resource: "validatingwebhookconfigurations"},
+		},
+	},
+}
+
+// capability is an escalation technique a subject is granted.
+type capability struct {
+	technique   string
+	description string
+	namespace   string
+	binding     BindingRef
+	rule        v1.PolicyRule
+	names       []string
+}
+
+// pivot is a subject another subject can act as.
+type pivot struct {
+	technique string
+	namespace string
+	binding   BindingRef
+	target    v1.Subject
+}
+
+// FindEscalations returns the escalation paths of every bound subject. The paths go through
+// the subjects a subject can act as, by creating pods or tokens of service accounts or by
+// impersonating them, up to subjects that can escalate or are cluster admins. Paths that
+// start from system subjects are only reported when includeSystem is set.
+func FindEscalations(bindings *Bindings, roles *Roles, includeSystem bool) []Escalation {
+	grants := ResolveGrants(bindings, roles)
+	subjects := boundSubjects(bindings)
+
+	capabilities := map[string][]capability{}
+	pivots := map[string][]pivot{}
+	for _, subject := range subjects {
+		key := subjectKey(subject)
+		id := IdentityFor(subject)
+		for _, grant := range grants {
+			if !grant.AppliesTo(id) {
+				continue
+			}
+			capabilities[key] = append(capabilities[key], grantCapabilities(grant)...)
+			pivots[key] = append(pivots[key], grantPivots(grant, subject, subjects)...)
+		}
+	}
+
+	escalations := []Escalation{}
+	for _, subject := range subjects {
+		if !includeSystem && IsSystemSubject(subject) {
+			continue
+		}
+		escalations = append(escalations, subjectEscalations(subject, capabilities, pivots)...)
+	}
+
+	return escalations
+}
+
+// subjectEscalations walks the subjects a subject can act as, breadth first, and returns the
+// shortest path to every technique the subject does not already hold.
+func subjectEscalations(subject v1.Subject, capabilities map[string][]capability, pivots map[string][]pivot) []Escalation {
+	var escalations []Escalation
+
+	held := map[string]bool{}
+	for _, c := range capabilities[subjectKey(subject)] {
+		held[c.technique] = true
+	}
+	// Cluster admins already hold every permission they could escalate to
+	if held[TechniqueClusterAdmin] {
+		return nil
+	}
+
+	reported := map[string]bool{}
+	for _, c := range capabilities[subjectKey(subject)] {
+		if reported[c.technique] {
+			continue
+		}
+		reported[c.technique] = true
+		escalations = append(escalations, newEscalation(subject, nil, c))
+	}
+
+	type node struct {
+		subject v1.Subject
+		path    []EscalationHop
+	}
+	visited := map[string]bool{subjectKey(subject): true}
+	queue := []node{{subject: subject}}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if len(current.path) >= maxEscalationHops {
+			continue
+		}
+
+		for _, p := range pivots[subjectKey(current.subject)] {
+			key := subjectKey(p.target)
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+
+			path := append(append([]EscalationHop{}, current.path...), EscalationHop{
+				Technique: p.technique,
+				Namespace: p.namespace,
+				Binding:   p.binding,
+				Subject:   p.target,
+			})
+			for _, c := range reachedCapabilities(capabilities[key]) {
+				// Every other technique is subsumed once a path to cluster admin is known
+				if held[c.technique] || reported[c.technique] || reported[TechniqueClusterAdmin] {
+					continue
+				}
+				reported[c.technique] = true
+				escalations = append(escalations, newEscalation(subject, path, c))
+			}
+			queue = append(queue, node{subject: p.target, path: path})
+		}
+	}
+
+	sort.SliceStable(escalations, func(i, j int) bool {
+		return len(escalations[i].Path) < len(escalations[j].Path)
+	})
+	return escalations
+}
+
+// reachedCapabilities returns the techniques worth reporting for a subject reached through a path,
+// which is only cluster admin for cluster admins.
+func reachedCapabilities(capabilities []capability) []capability {
+	for _, c := range capabilities {
+		if c.technique == TechniqueClusterAdmin {
+			return []capability{c}
+		}
+	}
+	return capabilities
+}
+
+func newEscalation(subject v1.Subject, path []EscalationHop, c capability) Escalation {
+	description := c.description
+	if len(path) > 0 {
+		last := path[len(path)-1].Subject
+		description = "acting as " + last.Kind + " " + qualifiedName(last) + ", " + c.description
+	}
+	if path == nil {
+		path = []EscalationHop{}
+	}
+
+	return Escalation{
+		Subject:       subject,
+		Path:          path,
+		Technique:     c.technique,
+		Description:   description,
+		Namespace:     c.namespace,
+		Binding:       c.binding,
+		Rule:          c.rule,
+		ResourceNames: c.names,
+	}
+}
+
+// grantCapabilities returns the escalation techniques a grant allows. Rules restricted with resourceNames
+// still allow the technique on the named objects, such as binding the cluster-admin ClusterRole, and the
+// capability is then restricted to those names.
+func grantCapabilities(grant Grant) []capability {
+	var capabilities []capability
+	add := func(technique string, description string, names []string) {
+		if names != nil {
+			description += ", restricted to " + strings.Join(names, ", ")
+		}
+		capabilities = append(capabilities, capability{
+			technique:   technique,
+			description: description,
+			namespace:   grant.Namespace,
+			binding:     grant.Binding,
+			rule:        grant.Rule,
+			names:       names,
+		})
+	}
+
+	if grant.Namespace == "" && isAdminRule(grant.Rule) {
+		add(TechniqueClusterAdmin, "has full access to every resource of the cluster", nil)
+	}
+
+	for _, check := range escalationChecks {
+		allowed, names := requestsAllowed(grant.Rule, check.requests)
+		if allowed {
+			add(check.technique, check.description, names)
+		}
+	}
+
+	// Impersonating any user or group includes the system:masters group
+	for _, resource := range []string{"users", "groups"} {
+		allowed, names := requestsAllowed(grant.Rule, []ResourceAttributes{{Verb: "impersonate", APIGroup: "", Resource: resource}})
+		if !allowed {
+			continue
+		}
+		if names == nil {
+			add(TechniqueImpersonate, "can impersonate any "+strings.TrimSuffix(resource, "s")+", including members of system:masters", nil)
+			break
+		}
+		add(TechniqueImpersonate, "can impersonate "+resource, names)
+	}
+
+	return capabilities
+}
+
+// requestsAllowed reports whether the rule allows any of the requests, and returns the resource names it
+// is restricted to, or nil when it allows the requests on every object.
+func requestsAllowed(rule v1.PolicyRule, requests []ResourceAttributes) (bool, []string) {
+	var names []string
+	for _, request := range requests {
+		allowed, requestNames := ruleAllowedNames(rule, request)
+		if allowed && requestNames == nil {
+			return true, nil
+		}
+		for _, name := range requestNames {
+			if !contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return len(names) > 0, names
+}
+
+// ruleAllowedNames reports whether the rule allows the request, and returns the resource names it is
+// restricted to, or nil when it allows the request on every object.
+func ruleAllowedNames(rule v1.PolicyRule, request ResourceAttributes) (bool, []string) {
+	if RuleAllows(rule, request) {
+		return true, nil
+	}
+
+	var names []string
+	for _, name := range rule.ResourceNames {
+		request.Name = name
+		if RuleAllows(rule, request) {
+			names = append(names, name)
+		}
+	}
+	return len(names) > 0, names
+}
+
+// grantPivots returns the bound subjects a grant lets the subject act as.
+func grantPivots(grant Grant, subject v1.Subject, subjects []v1.Subject) []pivot {
+	var pivots []pivot
+	add := func(technique string, target v1.Subject) {
+		if subjectKey(target) == subjectKey(subject) {
+			return
+		}
+		pivots = append(pivots, pivot{
+			technique: technique,
+			namespace: grant.Namespace,
+			binding:   grant.Binding,
+			target:    target,
+		})
+	}
+
+	// Pods can be created for any service account of the namespaces of the grant, and tokens for the
+	// service accounts the resourceNames of the rule allow
+	for _, technique := range []struct {
+		name    string
+		request ResourceAttributes
+	}{
+		{TechniqueCreatePods, ResourceAttributes{Verb: "create", APIGroup: "", Resource: "pods"}},
+		{TechniqueCreateToken, ResourceAttributes{Verb: "create", APIGroup: "", Resource: "serviceaccounts", Subresource: "token"}},
+	} {
+		allowed, names := ruleAllowedNames(grant.Rule, technique.request)
+		if !allowed {
+			continue
+		}
+		for _, target := range subjects {
+			if inServiceAccountScope(target, grant.Namespace, names) {
+				add(technique.name, target)
+			}
+		}
+	}
+
+	for _, resource := range []string{"serviceaccounts", "users", "groups"} {
+		// Rules restricted to resource names only allow impersonating those names
+		allowed, names := ruleAllowedNames(grant.Rule, ResourceAttributes{Verb: "impersonate", APIGroup: "", Resource: resource})
+		if !allowed {
+			continue
+		}
+
+		for _, target := range subjects {
+			switch {
+			case resource == "serviceaccounts" && inServiceAccountScope(target, grant.Namespace, names):
+				add(TechniqueImpersonate, target)
+			case resource == "users" && target.Kind == v1.UserKind && containsOrAll(names, target.Name):
+				add(TechniqueImpersonate, target)
+			case resource == "groups" && target.Kind == v1.GroupKind && containsOrAll(names, target.Name):
+				add(TechniqueImpersonate, target)
+			}
+		}
+	}
+
+	return pivots
+}
+
+// inServiceAccountScope reports whether the subject is a service account, or a group of service
+// accounts, of the namespace, or of any namespace when it is empty, with one of the names if any.
+func inServiceAccountScope(subject v1.Subject, namespace string, names []string) bool {
+	switch subject.Kind {
+	case v1.ServiceAccountKind:
+		return (namespace == "" || subject.Namespace == namespace) && containsOrAll(names, subject.Name)
+	case v1.GroupKind:
+		if names != nil {
+			return false
+		}
+		if subject.Name == ServiceAccountsGroup {
+			return namespace == ""
+		}
+		group, found := strings.CutPrefix(subject.Name, ServiceAccountsGroup+":")
+		return found && (namespace == "" || group == namespace)
+	}
+	return false
+}
+
+// containsOrAll reports whether names contains the name, or is nil.
+func containsOrAll(names []string, name string) bool {
+	if names == nil {
+		return true
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isAdminRule reports whether the rule allows every verb on every resource of every API group.
+func isAdminRule(rule v1.PolicyRule) bool {
+	return len(rule.ResourceNames) == 0 &&
+		contains(rule.Verbs, v1.VerbAll) &&
+		contains(rule.APIGroups, v1.APIGroupAll) &&
+		contains(rule.Resources, v1.ResourceAll)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSystemSubject reports whether the subject is managed by Kubernetes itself: users and groups with the
+// "system:" prefix and service accounts of kube-system. The groups of every authenticated or unauthenticated
+// user and of service accounts, and the anonymous user, are not considered system subjects.
+func IsSystemSubject(subject v1.Subject) bool {
+	switch subject.Kind {
+	case v1.ServiceAccountKind:
+		return subject.Namespace == "kube-system"
+	case v1.UserKind:
+		return subject.Name != AnonymousUser && strings.HasPrefix(subject.Name, "system:")
+	case v1.GroupKind:
+		switch {
+		case subject.Name == AllAuthenticatedGroup, subject.Name == AllUnauthenticatedGroup,
+			subject.Name == ServiceAccountsGroup, strings.HasPrefix(subject.Name, ServiceAccountsGroup+":"):
+			return false
+		}
+		return strings.HasPrefix(subject.Name, "system:")
+	}
+	return false
+}
+
+// qualifiedName returns the name of a subject, prefixed with its namespace when it has one.
+func qualifiedName(subject v1.Subject) string {
+	if subject.Namespace == "" {
+		return subject.Name
+	}
+	return subject.Namespace + "/" + subject.Name
+}
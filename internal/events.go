@@ -0,0 +1,186 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	EventAdded    = "ADDED"
+	EventModified = "MODIFIED"
+	EventDeleted  = "DELETED"
+)
+
+// Event is a change of a binding, in the same shape as the bindings served as Data.
+type Event struct {
+	Type string `json:"type"`
+	Data Data   `json:"data"`
+}
+
+// Broadcaster fans the binding changes of a store out to its subscribers. Changes are
+// coalesced per binding and sent in batches once per interval, so that bursts such as
+// a mass install do not flood the subscribers.
+type Broadcaster struct {
+	store    *Store
+	cluster  string
+	interval time.Duration
+
+	mu          sync.Mutex
+	pending     map[string]Event
+	order       []string
+	subscribers map[chan []Event]struct{}
+}
+
+// NewBroadcaster creates a broadcaster for the changes of the store, until the context is done.
+func NewBroadcaster(ctx context.Context, store *Store, cluster string, interval time.Duration) *Broadcaster {
+	b := &Broadcaster{
+		store:       store,
+		cluster:     cluster,
+		interval:    interval,
+		pending:     map[string]Event{},
+		subscribers: map[chan []Event]struct{}{},
+	}
+
+	store.AddListener(b.record)
+	go b.run(ctx)
+
+	return b
+}
+
+// Subscribe returns a channel receiving batches of events, and a function to cancel the subscription.
+// The channel is closed when the subscription is cancelled or if the subscriber falls behind.
+func (b *Broadcaster) Subscribe() (<-chan []Event, func()) {
+	ch := make(chan []Event, 16)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		// The channel is already closed if flush dropped the subscriber
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// record queues the change of a binding, merging it with a pending change of the same binding.
+func (b *Broadcaster) record(eventType string, obj runtime.Object) {
+	var data Data
+	switch o := obj.(type) {
+	case *v1.ClusterRoleBinding:
+		data = clusterRoleBindingData(*o, b.cluster)
+	case *v1.RoleBinding:
+		data = roleBindingData(*o, b.cluster)
+	default:
+		return
+	}
+
+	key := data.Kind + "/" + data.Namespace + "/" + data.Name
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	previous, pending := b.pending[key]
+	if !pending {
+		b.order = append(b.order, key)
+		b.pending[key] = Event{Type: eventType, Data: data}
+		return
+	}
+
+	switch {
+	case previous.Type == EventAdded && eventType == EventDeleted:
+		// The binding came and went within the same batch
+		delete(b.pending, key)
+	case previous.Type == EventAdded:
+		b.pending[key] = Event{Type: EventAdded, Data: data}
+	case previous.Type == EventDeleted && eventType == EventAdded:
+		b.pending[key] = Event{Type: EventModified, Data: data}
+	default:
+		b.pending[key] = Event{Type: eventType, Data: data}
+	}
+}
+
+func (b *Broadcaster) run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.flush()
+		}
+	}
+}
+
+func (b *Broadcaster) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.pending) == 0 {
+		b.order = nil
+		return
+	}
+
+	batch := make([]Event, 0, len(b.pending))
+	for _, key := range b.order {
+		if event, ok := b.pending[key]; ok {
+			batch = append(batch, event)
+		}
+	}
+	b.pending = map[string]Event{}
+	b.order = nil
+
+	// Findings and aggregation depend on the roles, so they are evaluated against the state of the store
+	// at the time of the batch
+	roles := b.store.Roles()
+	findings := FindFindings(b.store.Bindings(), roles, b.cluster, false)
+	for i := range batch {
+		data := []Data{batch[i].Data}
+		AttachFindings(data, findings)
+		AttachAggregation(data, roles)
+		batch[i].Data = data[0]
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- batch:
+		default:
+			// The subscriber is not keeping up, close it so that it reconnects and reloads the bindings
+			close(ch)
+			delete(b.subscribers, ch)
+		}
+	}
+}
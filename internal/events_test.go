@@ -0,0 +1,77 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestBroadcasterCancel checks that cancelling a subscription ends the goroutines ranging over its channel,
+// also when the subscriber was already dropped for falling behind.
+func TestBroadcasterCancel(t *testing.T) {
+	ctx, cancelBroadcaster := context.WithCancel(context.Background())
+	defer cancelBroadcaster()
+
+	store := NewStore()
+	b := NewBroadcaster(ctx, store, "", time.Hour)
+
+	ch, cancel := b.Subscribe()
+	done := make(chan struct{})
+	go func() {
+		for range ch {
+		}
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the subscriber goroutine did not exit after the subscription was cancelled")
+	}
+	// Cancelling twice is a no-op
+	cancel()
+
+	slow, cancelSlow := b.Subscribe()
+	for i := 0; i <= cap(slow); i++ {
+		store.Add(&v1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "binding"}})
+		b.flush()
+	}
+	if _, ok := <-drain(slow); ok {
+		t.Fatal("the channel of a subscriber that fell behind was not closed")
+	}
+	cancelSlow()
+}
+
+// drain returns the channel once every batch buffered in it has been received.
+func drain(ch <-chan []Event) <-chan []Event {
+	for range len(ch) {
+		<-ch
+	}
+	return ch
+}
@@ -0,0 +1,437 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/rbac/v1"
+)
+
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+)
+
+// severityWeights are the weights of the severities in risk scores, higher is riskier.
+var severityWeights = map[string]int{
+	SeverityCritical: 10,
+	SeverityHigh:     5,
+	SeverityMedium:   2,
+	SeverityLow:      1,
+}
+
+// Check is a security check of the catalogue, evaluated against every binding.
+type Check struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+	Remediation string `json:"remediation"`
+	// evaluate returns the evidence of the check for a binding, or an empty string when it passes
+	evaluate func(b boundRole) string
+}
+
+// Finding is a failed check for a binding, or a policy violation of a subject.
+type Finding struct {
+	Cluster     string     `json:"cluster,omitempty"`
+	CheckID     string     `json:"checkId"`
+	Title       string     `json:"title"`
+	Severity    string     `json:"severity"`
+	Description string     `json:"description"`
+	Remediation string     `json:"remediation"`
+	NodeID      string     `json:"nodeId"`
+	Binding     BindingRef `json:"binding"`
+	// Subject is the subject that violates a policy
+	Subject  *v1.Subject `json:"subject,omitempty"`
+	RoleRef  v1.RoleRef  `json:"roleRef"`
+	Evidence string      `json:"evidence"`
+	// Source is the file the binding was loaded from, when it was loaded from files
+	Source string `json:"source,omitempty"`
+}
+
+// boundRole is a binding with the rules of the role it references.
+type boundRole struct {
+	binding  BindingRef
+	subjects []v1.Subject
+	roleRef  v1.RoleRef
+	rules    []v1.PolicyRule
+	// system is set for the default bindings of Kubernetes
+	system bool
+}
+
+const (
+	// clusterAdminCheck is the ID of the check of cluster-admin grants.
+	clusterAdminCheck = "RBAC001"
+	// anonymousAccessCheck is the ID of the check of anonymous access, which is never suppressed.
+	anonymousAccessCheck = "RBAC002"
+)
+
+// Checks is the catalogue of built-in security checks.
+var Checks = []Check{
+	{
+		ID:          clusterAdminCheck,
+		Title:       "cluster-admin granted to a non-system subject",
+		Severity:    SeverityCritical,
+		Description: "The binding grants full access to every resource of the cluster to a subject that is not managed by Kubernetes.",
+		Remediation: "Bind a role limited to the resources the subject needs, and keep cluster-admin for break-glass access.",
+		evaluate: func(b boundRole) string {
+			// Bindings without subjects grant nothing
+			if b.binding.Kind != ClusterRoleBindingKind || len(b.subjects) == 0 {
+				return ""
+			}
+			for _, rule := range b.rules {
+				if isAdminRule(rule) {
+					return "grants every verb on every resource to " + subjectNames(b.subjects)
+				}
+			}
+			return ""
+		},
+	},
+	{
+		ID:          anonymousAccessCheck,
+		Title:       "Anonymous access",
+		Severity:    SeverityCritical,
+		Description: "The binding grants permissions to unauthenticated requests, through the system:anonymous user or the system:unauthenticated group.",
+		Remediation: "Remove system:anonymous and system:unauthenticated from the subjects of the binding.",
+		evaluate: func(b boundRole) string {
+			for _, subject := range b.subjects {
+				if (subject.Kind == v1.UserKind && subject.Name == AnonymousUser) ||
+					(subject.Kind == v1.GroupKind && subject.Name == AllUnauthenticatedGroup) {
+					return "binds " + subject.Kind + " " + subject.Name
+				}
+			}
+			return ""
+		},
+	},
+	{
+		ID:          "RBAC003",
+		Title:       "Access for every authenticated user",
+		Severity:    SeverityHigh,
+		Description: "The binding grants permissions to the system:authenticated group, which every user and service account of the cluster is a member of.",
+		Remediation: "Bind the role to the groups or service accounts that need it instead of system:authenticated.",
+		evaluate: func(b boundRole) string {
+			for _, subject := range b.subjects {
+				if subject.Kind == v1.GroupKind && subject.Name == AllAuthenticatedGroup {
+					return "binds Group " + subject.Name
+				}
+			}
+			return ""
+		},
+	},
+	{
+		ID:          "RBAC004",
+		Title:       "Wildcard verbs",
+		Severity:    SeverityHigh,
+		Description: "A rule of the bound role allows every verb, including verbs added to the API in the future.",
+		Remediation: "List the verbs the subjects need explicitly.",
+		evaluate: func(b boundRole) string {
+			return firstRule(b.rules, func(rule v1.PolicyRule) bool { return contains(rule.Verbs, v1.VerbAll) })
+		},
+	},
+	{
+		ID:          "RBAC005",
+		Title:       "Wildcard resources",
+		Severity:    SeverityHigh,
+		Description: "A rule of the bound role allows every resource of its API groups, including resources added in the future.",
+		Remediation: "List the resources the subjects need explicitly.",
+		evaluate: func(b boundRole) string {
+			return firstRule(b.rules, func(rule v1.PolicyRule) bool { return contains(rule.Resources, v1.ResourceAll) })
+		},
+	},
+	{
+		ID:          "RBAC006",
+		Title:       "Secrets readable cluster-wide",
+		Severity:    SeverityHigh,
+		Description: "The binding allows reading the secrets of every namespace, including service account tokens and credentials of other workloads.",
+		Remediation: "Grant access to secrets with RoleBindings in the namespaces that need it, restricted with resourceNames where possible.",
+		evaluate: func(b boundRole) string {
+			if b.binding.Kind != ClusterRoleBindingKind {
+				return ""
+			}
+			return firstRule(b.rules, func(rule v1.PolicyRule) bool {
+				for _, verb := range []string{"get", "list", "watch"} {
+					if RuleAllows(rule, ResourceAttributes{Verb: verb, APIGroup: "", Resource: "secrets"}) {
+						return true
+					}
+				}
+				return false
+			})
+		},
+	},
+	{
+		ID:          "RBAC007",
+		Title:       "Access to the kubelet API through nodes/proxy",
+		Severity:    SeverityHigh,
+		Description: "The nodes/proxy subresource gives direct access to the kubelet API, which allows running commands in any pod of the node and bypasses admission control and audit logging.",
+		Remediation: "Remove nodes/proxy from the role, and use the metrics or logs APIs for monitoring instead.",
+		evaluate: func(b boundRole) string {
+			return firstRule(b.rules, func(rule v1.PolicyRule) bool {
+				return RuleAllows(rule, ResourceAttributes{Verb: "get", APIGroup: "", Resource: "nodes", Subresource: "proxy"}) ||
+					RuleAllows(rule, ResourceAttributes{Verb: "create", APIGroup: "", Resource: "nodes", Subresource: "proxy"})
+			})
+		},
+	},
+	{
+		ID:          "RBAC008",
+		Title:       "Command execution in pods",
+		Severity:    SeverityMedium,
+		Description: "The binding allows executing commands in or attaching to running containers, which exposes their service account tokens and mounted secrets.",
+		Remediation: "Limit pods/exec and pods/attach to the namespaces and subjects that need interactive access.",
+		evaluate: func(b boundRole) string {
+			return firstRule(b.rules, func(rule v1.PolicyRule) bool {
+				return RuleAllows(rule, ResourceAttributes{Verb: "create", APIGroup: "", Resource: "pods", Subresource: "exec"}) ||
+					RuleAllows(rule, ResourceAttributes{Verb: "create", APIGroup: "", Resource: "pods", Subresource: "attach"})
+			})
+		},
+	},
+	{
+		ID:          "RBAC009",
+		Title:       "Permissions granted to a default service account",
+		Severity:    SeverityMedium,
+		Description: "Every pod that does not set a service account runs as the default service account of its namespace, so its permissions are shared with all of them.",
+		Remediation: "Create a dedicated service account for the workload and bind the role to it instead.",
+		evaluate: func(b boundRole) string {
+			for _, subject := range b.subjects {
+				if subject.Kind == v1.ServiceAccountKind && subject.Name == "default" {
+					return "binds ServiceAccount " + qualifiedName(subject)
+				}
+			}
+			return ""
+		},
+	},
+	{
+		ID:          "RBAC010",
+		Title:       "Privilege escalation",
+		Severity:    SeverityHigh,
+		Description: "The binding allows gaining permissions beyond the ones granted, through the escalate, bind or impersonate verbs, changes to bindings, certificate approval or admission webhooks.",
+		Remediation: "Remove the escalation verbs from the role, or restrict them with resourceNames to the roles and identities that are safe to use.",
+		evaluate: func(b boundRole) string {
+			for _, rule := range b.rules {
+				for _, c := range grantCapabilities(Grant{Binding: b.binding, Namespace: b.binding.Namespace, Rule: rule}) {
+					if c.technique != TechniqueClusterAdmin {
+						return c.technique + ": " + c.description
+					}
+				}
+			}
+			return ""
+		},
+	},
+}
+
+// FindFindings runs the checks of the catalogue against every binding of the cluster. Bindings
+// managed by Kubernetes, which have the rbac-defaults bootstrapping label or only system subjects,
+// are only checked when includeSystem is set, except for anonymous access which is always reported.
+// Findings are sorted by severity.
+func FindFindings(bindings *Bindings, roles *Roles, cluster string, includeSystem bool) []Finding {
+	index := newRoleIndex(roles)
+	findings := []Finding{}
+
+	check := func(b boundRole, nodeID string) {
+		system := !includeSystem && isSystemBinding(b)
+		subjects := b.subjects
+		if !includeSystem {
+			b.subjects = nonSystemSubjects(b.subjects)
+		}
+		// The cluster-admin finding of a ClusterRoleBinding subsumes the findings of its admin rules
+		rest := b
+		if b.binding.Kind == ClusterRoleBindingKind {
+			rest.rules = nil
+			for _, rule := range b.rules {
+				if !isAdminRule(rule) {
+					rest.rules = append(rest.rules, rule)
+				}
+			}
+		}
+		for _, c := range Checks {
+			if system && c.ID != anonymousAccessCheck {
+				continue
+			}
+			target := rest
+			switch c.ID {
+			case clusterAdminCheck:
+				target = b
+			case anonymousAccessCheck:
+				target.subjects = subjects
+			}
+			evidence := c.evaluate(target)
+			if evidence == "" {
+				continue
+			}
+			findings = append(findings, Finding{
+				Cluster:     cluster,
+				CheckID:     c.ID,
+				Title:       c.Title,
+				Severity:    c.Severity,
+				Description: c.Description,
+				Remediation: c.Remediation,
+				NodeID:      nodeID,
+				Binding:     b.binding,
+				RoleRef:     b.roleRef,
+				Evidence:    evidence,
+			})
+		}
+	}
+
+	if bindings.ClusterRoleBindings != nil {
+		for _, crb := range bindings.ClusterRoleBindings.Items {
+			rules, _ := index.rules(crb.RoleRef, "")
+			check(boundRole{
+				binding:  BindingRef{Kind: ClusterRoleBindingKind, Name: crb.Name},
+				subjects: crb.Subjects,
+				roleRef:  crb.RoleRef,
+				rules:    rules,
+				system:   crb.Labels[bootstrapLabel] == "rbac-defaults",
+			}, NodeID(cluster, ClusterRoleBindingKind, "", crb.Name))
+		}
+	}
+
+	if bindings.RoleBindings != nil {
+		for _, rb := range bindings.RoleBindings.Items {
+			rules, _ := index.rules(rb.RoleRef, rb.Namespace)
+			check(boundRole{
+				binding:  BindingRef{Kind: RoleBindingKind, Name: rb.Name, Namespace: rb.Namespace},
+				subjects: defaultSubjectNamespaces(rb.Subjects, rb.Namespace),
+				roleRef:  rb.RoleRef,
+				rules:    rules,
+				system:   rb.Labels[bootstrapLabel] == "rbac-defaults",
+			}, NodeID(cluster, RoleBindingKind, rb.Namespace, rb.Name))
+		}
+	}
+
+	SortFindings(findings)
+	return findings
+}
+
+// SortFindings sorts findings by severity, from the most severe, keeping the order of findings of the same severity.
+func SortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityWeights[findings[i].Severity] > severityWeights[findings[j].Severity]
+	})
+}
+
+// AttachFindings sets the findings and the risk score of the data rows of the bindings they were found for.
+func AttachFindings(data []Data, findings []Finding) {
+	byNode := map[string][]Finding{}
+	for _, f := range findings {
+		byNode[f.NodeID] = append(byNode[f.NodeID], f)
+	}
+
+	for i := range data {
+		data[i].Findings = byNode[data[i].Id]
+		data[i].RiskScore = RiskScore(data[i].Findings)
+	}
+}
+
+// RiskScore sums the severity weights of the findings.
+func RiskScore(findings []Finding) int {
+	score := 0
+	for _, f := range findings {
+		score += severityWeights[f.Severity]
+	}
+	return score
+}
+
+// ValidSeverity reports whether the severity is one of the known severities.
+func ValidSeverity(severity string) bool {
+	_, ok := severityWeights[severity]
+	return ok
+}
+
+// SeverityAtLeast reports whether the severity is the same as or higher than the threshold.
+func SeverityAtLeast(severity string, threshold string) bool {
+	return severityWeights[severity] >= severityWeights[threshold]
+}
+
+func isSystemBinding(b boundRole) bool {
+	if b.system {
+		return true
+	}
+	return len(b.subjects) > 0 && len(nonSystemSubjects(b.subjects)) == 0
+}
+
+func nonSystemSubjects(subjects []v1.Subject) []v1.Subject {
+	var result []v1.Subject
+	for _, subject := range subjects {
+		if !IsSystemSubject(subject) {
+			result = append(result, subject)
+		}
+	}
+	return result
+}
+
+// defaultSubjectNamespaces returns the subjects with the namespace of service accounts defaulted.
+func defaultSubjectNamespaces(subjects []v1.Subject, namespace string) []v1.Subject {
+	result := make([]v1.Subject, 0, len(subjects))
+	for _, subject := range subjects {
+		if subject.Kind == v1.ServiceAccountKind && subject.Namespace == "" {
+			subject.Namespace = namespace
+		}
+		result = append(result, subject)
+	}
+	return result
+}
+
+// FormatRule renders a policy rule on a single line.
+func FormatRule(rule v1.PolicyRule) string {
+	parts := []string{"verbs=" + strings.Join(rule.Verbs, ",")}
+	if len(rule.APIGroups) > 0 {
+		groups := make([]string, 0, len(rule.APIGroups))
+		for _, group := range rule.APIGroups {
+			groups = append(groups, fmt.Sprintf("%q", group))
+		}
+		parts = append(parts, "apiGroups="+strings.Join(groups, ","))
+	}
+	if len(rule.Resources) > 0 {
+		parts = append(parts, "resources="+strings.Join(rule.Resources, ","))
+	}
+	if len(rule.ResourceNames) > 0 {
+		parts = append(parts, "resourceNames="+strings.Join(rule.ResourceNames, ","))
+	}
+	if len(rule.NonResourceURLs) > 0 {
+		parts = append(parts, "nonResourceURLs="+strings.Join(rule.NonResourceURLs, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// firstRule returns the first rule matching the predicate, rendered as evidence.
+func firstRule(rules []v1.PolicyRule, predicate func(rule v1.PolicyRule) bool) string {
+	for _, rule := range rules {
+		if predicate(rule) {
+			return "rule " + FormatRule(rule)
+		}
+	}
+	return ""
+}
+
+func subjectNames(subjects []v1.Subject) string {
+	names := make([]string, 0, len(subjects))
+	for _, subject := range subjects {
+		names = append(names, subject.Kind+" "+qualifiedName(subject))
+	}
+	return strings.Join(names, ", ")
+}
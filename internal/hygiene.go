@@ -0,0 +1,209 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Hygiene issue types
+const (
+	HygieneUnusedRole            = "unused-role"
+	HygieneMissingRole           = "missing-role"
+	HygieneMissingServiceAccount = "missing-service-account"
+	HygieneDeletedNamespace      = "deleted-namespace"
+)
+
+// bootstrapLabel marks the default roles and bindings the API server creates and reconciles.
+const bootstrapLabel = "kubernetes.io/bootstrapping"
+
+// HygieneIssue is an RBAC object that is unused or points to an object that does not exist.
+type HygieneIssue struct {
+	Cluster   string `json:"cluster,omitempty"`
+	Type      string `json:"type"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// Reference is the role, service account or namespace the object points to that does not exist
+	Reference   string `json:"reference,omitempty"`
+	Description string `json:"description"`
+	Source      string `json:"source,omitempty"`
+}
+
+// FindHygieneIssues finds the roles no binding references, the bindings whose role does not exist, the bindings of
+// service accounts that do not exist and the roles and bindings left in namespaces that do not exist or are being
+// deleted. ClusterRoles aggregated into another ClusterRole count as referenced. Service accounts and namespaces are
+// only checked when the source holds any, so manifests without them are not reported as dangling. Objects managed by
+// Kubernetes are left out unless includeSystem is set.
+func FindHygieneIssues(bindings *Bindings, roles *Roles, serviceAccounts *corev1.ServiceAccountList, namespaces *corev1.NamespaceList, includeSystem bool) []HygieneIssue {
+	var issues []HygieneIssue
+
+	clusterRoles := map[string]bool{}
+	for _, cr := range roles.ClusterRoles.Items {
+		clusterRoles[cr.Name] = true
+	}
+	namespacedRoles := map[string]bool{}
+	for _, r := range roles.Roles.Items {
+		namespacedRoles[objectKey(r.Namespace, r.Name)] = true
+	}
+
+	sas := map[string]bool{}
+	if serviceAccounts != nil {
+		for _, sa := range serviceAccounts.Items {
+			sas[objectKey(sa.Namespace, sa.Name)] = true
+		}
+	}
+	// namespaceStates holds whether each namespace is active, as opposed to being deleted
+	namespaceStates := map[string]bool{}
+	if namespaces != nil {
+		for _, ns := range namespaces.Items {
+			namespaceStates[ns.Name] = ns.DeletionTimestamp == nil && ns.Status.Phase != corev1.NamespaceTerminating
+		}
+	}
+	namespaceMissing := func(namespace string) bool {
+		return len(namespaceStates) > 0 && !namespaceStates[namespace]
+	}
+	namespaceIssue := func(namespace string) string {
+		if _, ok := namespaceStates[namespace]; ok {
+			return fmt.Sprintf("namespace %s is being deleted", namespace)
+		}
+		return fmt.Sprintf("namespace %s does not exist", namespace)
+	}
+
+	referenced := map[string]bool{}
+	checkBinding := func(kind string, meta metav1.ObjectMeta, subjects []v1.Subject, roleRef v1.RoleRef) {
+		referenced[RoleRefID("", roleRef, meta.Namespace)] = true
+		if !includeSystem && isSystemObject(meta) {
+			return
+		}
+
+		issue := HygieneIssue{Kind: kind, Name: meta.Name, Namespace: meta.Namespace}
+		if meta.Namespace != "" && namespaceMissing(meta.Namespace) {
+			issue.Type, issue.Reference = HygieneDeletedNamespace, meta.Namespace
+			issue.Description = namespaceIssue(meta.Namespace)
+			issues = append(issues, issue)
+		}
+
+		exists := clusterRoles[roleRef.Name]
+		if roleRef.Kind == RoleKind {
+			exists = namespacedRoles[objectKey(meta.Namespace, roleRef.Name)]
+		}
+		if !exists {
+			issue.Type, issue.Reference = HygieneMissingRole, RoleRefID("", roleRef, meta.Namespace)
+			issue.Description = fmt.Sprintf("%s %s does not exist", roleRef.Kind, roleRef.Name)
+			issues = append(issues, issue)
+		}
+
+		if serviceAccounts == nil || len(serviceAccounts.Items) == 0 {
+			return
+		}
+		for _, subject := range defaultSubjectNamespaces(subjects, meta.Namespace) {
+			if subject.Kind != v1.ServiceAccountKind || sas[objectKey(subject.Namespace, subject.Name)] {
+				continue
+			}
+			issue.Type, issue.Reference = HygieneMissingServiceAccount, SubjectID("", subject, meta.Namespace)
+			issue.Description = fmt.Sprintf("service account %s/%s does not exist", subject.Namespace, subject.Name)
+			if namespaceMissing(subject.Namespace) {
+				issue.Description += ", " + namespaceIssue(subject.Namespace)
+			}
+			issues = append(issues, issue)
+		}
+	}
+
+	for _, crb := range bindings.ClusterRoleBindings.Items {
+		checkBinding(ClusterRoleBindingKind, crb.ObjectMeta, crb.Subjects, crb.RoleRef)
+	}
+	for _, rb := range bindings.RoleBindings.Items {
+		checkBinding(RoleBindingKind, rb.ObjectMeta, rb.Subjects, rb.RoleRef)
+	}
+
+	components := map[string]bool{}
+	for _, cr := range roles.ClusterRoles.Items {
+		for _, component := range AggregationComponents(cr, roles.ClusterRoles.Items) {
+			components[component] = true
+		}
+	}
+	for _, cr := range roles.ClusterRoles.Items {
+		if referenced[NodeID("", ClusterRoleKind, "", cr.Name)] || components[cr.Name] ||
+			(!includeSystem && isSystemObject(cr.ObjectMeta)) {
+			continue
+		}
+		issues = append(issues, HygieneIssue{
+			Type:        HygieneUnusedRole,
+			Kind:        ClusterRoleKind,
+			Name:        cr.Name,
+			Description: "no binding references the ClusterRole",
+		})
+	}
+
+	for _, r := range roles.Roles.Items {
+		if !includeSystem && isSystemObject(r.ObjectMeta) {
+			continue
+		}
+		if namespaceMissing(r.Namespace) {
+			issues = append(issues, HygieneIssue{
+				Type:        HygieneDeletedNamespace,
+				Kind:        RoleKind,
+				Name:        r.Name,
+				Namespace:   r.Namespace,
+				Reference:   r.Namespace,
+				Description: namespaceIssue(r.Namespace),
+			})
+		}
+		if !referenced[NodeID("", RoleKind, r.Namespace, r.Name)] {
+			issues = append(issues, HygieneIssue{
+				Type:        HygieneUnusedRole,
+				Kind:        RoleKind,
+				Name:        r.Name,
+				Namespace:   r.Namespace,
+				Description: "no binding in the namespace references the Role",
+			})
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Type != issues[j].Type {
+			return issues[i].Type < issues[j].Type
+		}
+		if issues[i].Kind != issues[j].Kind {
+			return issues[i].Kind < issues[j].Kind
+		}
+		if issues[i].Namespace != issues[j].Namespace {
+			return issues[i].Namespace < issues[j].Namespace
+		}
+		return issues[i].Name < issues[j].Name
+	})
+	return issues
+}
+
+// isSystemObject returns whether the object is one of the default roles and bindings of Kubernetes.
+func isSystemObject(meta metav1.ObjectMeta) bool {
+	return strings.HasPrefix(meta.Name, "system:") || meta.Labels[bootstrapLabel] == "rbac-defaults" ||
+		meta.Namespace == "kube-system" || meta.Namespace == "kube-public"
+}
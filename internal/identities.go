@@ -0,0 +1,426 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// OpenShiftGroupKind and OpenShiftUserKind are the store kinds of the Group and User objects of OpenShift
+	OpenShiftGroupKind = "Group.user.openshift.io"
+	OpenShiftUserKind  = "User.user.openshift.io"
+
+	openShiftUserGroup = "user.openshift.io"
+)
+
+// IdentityMap maps users to the groups they are members of in an identity provider, which
+// Kubernetes only learns about when the users authenticate.
+type IdentityMap struct {
+	groups map[string][]string
+}
+
+// identityFile is the format of YAML and JSON identity files, which list the groups of users,
+// the members of groups, or both.
+type identityFile struct {
+	Users  map[string][]string `json:"users"`
+	Groups map[string][]string `json:"groups"`
+}
+
+func NewIdentityMap() *IdentityMap {
+	return &IdentityMap{groups: map[string][]string{}}
+}
+
+// Add records the user as a member of the group.
+func (m *IdentityMap) Add(user string, group string) {
+	if user == "" || group == "" || contains(m.groups[user], group) {
+		return
+	}
+	m.groups[user] = append(m.groups[user], group)
+	sort.Strings(m.groups[user])
+}
+
+// Merge adds the memberships of another map.
+func (m *IdentityMap) Merge(other *IdentityMap) {
+	if other == nil {
+		return
+	}
+	for user, groups := range other.groups {
+		for _, group := range groups {
+			m.Add(user, group)
+		}
+	}
+}
+
+// Groups returns the groups of the user.
+func (m *IdentityMap) Groups(user string) []string {
+	if m == nil {
+		return nil
+	}
+	return m.groups[user]
+}
+
+// Members returns the users of the group, sorted by name.
+func (m *IdentityMap) Members(group string) []string {
+	if m == nil {
+		return nil
+	}
+	var members []string
+	for user, groups := range m.groups {
+		if contains(groups, group) {
+			members = append(members, user)
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
+// GroupMembers returns the members of every group, by group name.
+func (m *IdentityMap) GroupMembers() map[string][]string {
+	members := map[string][]string{}
+	if m == nil {
+		return members
+	}
+	for user, groups := range m.groups {
+		for _, group := range groups {
+			members[group] = append(members[group], user)
+		}
+	}
+	for group := range members {
+		sort.Strings(members[group])
+	}
+	return members
+}
+
+// IdentityFor returns the identity a subject authenticates as, with the groups of the map added to users.
+func (m *IdentityMap) IdentityFor(subject v1.Subject) Identity {
+	id := IdentityFor(subject)
+	if subject.Kind != v1.UserKind {
+		return id
+	}
+	for _, group := range m.Groups(subject.Name) {
+		if !contains(id.Groups, group) {
+			id.Groups = append(id.Groups, group)
+		}
+	}
+	return id
+}
+
+// LoadIdentityMap reads the group memberships of users from YAML, JSON, CSV and LDIF files.
+// CSV files have a user and its groups on every row, and LDIF files are read for the members of groups
+// and the memberOf attribute of users, naming users and groups after the first value of their DNs.
+func LoadIdentityMap(paths []string) (*IdentityMap, error) {
+	m := NewIdentityMap()
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			err = m.readCSV(content)
+		case ".ldif":
+			err = m.readLDIF(content)
+		case ".yaml", ".yml", ".json":
+			err = m.readYAML(content)
+		default:
+			err = fmt.Errorf("unsupported format, must be YAML, JSON, CSV or LDIF")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read identities from %s: %w", path, err)
+		}
+	}
+	return m, nil
+}
+
+func (m *IdentityMap) readYAML(content []byte) error {
+	var file identityFile
+	if err := yaml.UnmarshalStrict(content, &file); err != nil {
+		return err
+	}
+	for user, groups := range file.Users {
+		for _, group := range groups {
+			m.Add(user, group)
+		}
+	}
+	for group, users := range file.Groups {
+		for _, user := range users {
+			m.Add(user, group)
+		}
+	}
+	return nil
+}
+
+func (m *IdentityMap) readCSV(content []byte) error {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	for line := 0; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// A header row is skipped
+		if line == 0 && strings.EqualFold(record[0], "user") {
+			continue
+		}
+		for _, group := range record[1:] {
+			m.Add(strings.TrimSpace(record[0]), strings.TrimSpace(group))
+		}
+	}
+}
+
+func (m *IdentityMap) readLDIF(content []byte) error {
+	entries, err := parseLDIF(content)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if group := firstValue(entry, "cn"); isLDIFGroup(entry) && group != "" {
+			for _, dn := range append(entry["member"], entry["uniquemember"]...) {
+				m.Add(rdnValue(dn), group)
+			}
+			for _, user := range entry["memberuid"] {
+				m.Add(user, group)
+			}
+			continue
+		}
+
+		user := firstValue(entry, "uid")
+		if user == "" {
+			user = firstValue(entry, "samaccountname")
+		}
+		if user == "" {
+			user = rdnValue(firstValue(entry, "dn"))
+		}
+		for _, dn := range entry["memberof"] {
+			m.Add(user, rdnValue(dn))
+		}
+	}
+	return nil
+}
+
+// parseLDIF parses the entries of LDIF content into their attributes, keyed by lower case name.
+func parseLDIF(content []byte) ([]map[string][]string, error) {
+	var entries []map[string][]string
+	var lines []string
+
+	flush := func() error {
+		if len(lines) == 0 {
+			return nil
+		}
+		entry := map[string][]string{}
+		for _, line := range lines {
+			name, value, ok := strings.Cut(line, ":")
+			if !ok {
+				return fmt.Errorf("invalid line %q", line)
+			}
+			switch {
+			case strings.HasPrefix(value, ":"):
+				decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+				if err != nil {
+					return fmt.Errorf("invalid base64 value of %s: %w", name, err)
+				}
+				value = string(decoded)
+			case strings.HasPrefix(value, "<"):
+				// Values referenced by URL are not read
+				continue
+			default:
+				value = strings.TrimSpace(value)
+			}
+			name = strings.ToLower(strings.TrimSpace(name))
+			entry[name] = append(entry[name], value)
+		}
+		entries = append(entries, entry)
+		lines = nil
+		return nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case line == "":
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, " ") && len(lines) > 0:
+			// Folded lines continue the previous line
+			lines[len(lines)-1] += line[1:]
+		case strings.HasPrefix(strings.ToLower(line), "version:") && len(entries) == 0 && len(lines) == 0:
+		default:
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func isLDIFGroup(entry map[string][]string) bool {
+	for _, class := range entry["objectclass"] {
+		switch strings.ToLower(class) {
+		case "groupofnames", "groupofuniquenames", "posixgroup", "group":
+			return true
+		}
+	}
+	return false
+}
+
+func firstValue(entry map[string][]string, name string) string {
+	if values := entry[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// rdnValue returns the value of the first relative distinguished name of a DN,
+// e.g. "alice" for "uid=alice,ou=people,dc=example,dc=com".
+func rdnValue(dn string) string {
+	// The optional unique identifier of uniqueMember values follows a '#'
+	dn, _, _ = strings.Cut(dn, "#")
+	rdn, _, _ := strings.Cut(dn, ",")
+	_, value, ok := strings.Cut(rdn, "=")
+	if !ok {
+		return strings.TrimSpace(rdn)
+	}
+	return strings.TrimSpace(value)
+}
+
+// GetIdentities returns the identity map of the app merged with the Group and User objects of
+// OpenShift, when the cluster has any.
+func (app App) GetIdentities() (*IdentityMap, error) {
+	m := NewIdentityMap()
+	m.Merge(app.Identities)
+
+	objects, err := app.getOpenShiftIdentities()
+	if err != nil {
+		return nil, err
+	}
+	m.Merge(openShiftIdentityMap(objects))
+
+	return m, nil
+}
+
+// getOpenShiftIdentities returns the Group and User objects of OpenShift, or nothing when the
+// cluster does not serve them.
+func (app App) getOpenShiftIdentities() ([]runtime.Object, error) {
+	if app.Store != nil {
+		return append(app.Store.List(OpenShiftGroupKind), app.Store.List(OpenShiftUserKind)...), nil
+	}
+	if app.KubeClient == nil {
+		return nil, nil
+	}
+
+	if _, err := app.KubeClient.Discovery().ServerResourcesForGroupVersion(openShiftUserGroup + "/v1"); err != nil {
+		return nil, nil
+	}
+
+	var objects []runtime.Object
+	for _, resource := range []string{"groups", "users"} {
+		raw, err := app.KubeClient.Discovery().RESTClient().Get().
+			AbsPath("/apis", openShiftUserGroup, "v1", resource).
+			DoRaw(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list OpenShift %s: %w", resource, err)
+		}
+		list := &unstructured.UnstructuredList{}
+		if err := list.UnmarshalJSON(raw); err != nil {
+			return nil, fmt.Errorf("failed to decode OpenShift %s: %w", resource, err)
+		}
+		for i := range list.Items {
+			objects = append(objects, &list.Items[i])
+		}
+	}
+
+	return objects, nil
+}
+
+// openShiftIdentityMap reads the members of OpenShift Groups and the groups of OpenShift Users.
+func openShiftIdentityMap(objects []runtime.Object) *IdentityMap {
+	m := NewIdentityMap()
+	for _, obj := range objects {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		switch openShiftKind(u) {
+		case OpenShiftGroupKind:
+			users, _, _ := unstructured.NestedStringSlice(u.Object, "users")
+			for _, user := range users {
+				m.Add(user, u.GetName())
+			}
+		case OpenShiftUserKind:
+			groups, _, _ := unstructured.NestedStringSlice(u.Object, "groups")
+			for _, group := range groups {
+				m.Add(u.GetName(), group)
+			}
+		}
+	}
+	return m
+}
+
+// openShiftKind returns the store kind of an OpenShift Group or User object, or an empty string.
+func openShiftKind(u *unstructured.Unstructured) string {
+	gvk := u.GroupVersionKind()
+	if gvk.Group != openShiftUserGroup {
+		return ""
+	}
+	switch gvk.Kind {
+	case "Group":
+		return OpenShiftGroupKind
+	case "User":
+		return OpenShiftUserKind
+	}
+	return ""
+}
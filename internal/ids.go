@@ -0,0 +1,74 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"net/url"
+	"strings"
+
+	v1 "k8s.io/api/rbac/v1"
+)
+
+// NodeID returns the canonical identity of an object, used consistently across the API, the graph
+// and the table. It joins the cluster, kind, namespace and name of the object with slashes, always in
+// these four positions, and escapes slashes within them, so that IDs are unique even for context names
+// like "arn:aws:eks:eu-west-1:123456789012:cluster/prod". The cluster and the namespace are empty when
+// unset. Node IDs are stable across requests.
+func NodeID(cluster string, kind string, namespace string, name string) string {
+	parts := []string{cluster, kind, namespace, name}
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// SubjectID returns the node ID of a subject of a binding in the given namespace.
+// Service accounts without a namespace default to the namespace of the binding.
+func SubjectID(cluster string, subject v1.Subject, bindingNamespace string) string {
+	namespace := ""
+	if subject.Kind == v1.ServiceAccountKind {
+		namespace = subject.Namespace
+		if namespace == "" {
+			namespace = bindingNamespace
+		}
+	}
+	return NodeID(cluster, subject.Kind, namespace, subject.Name)
+}
+
+// RoleRefID returns the node ID of the role referenced by a binding in the given namespace.
+func RoleRefID(cluster string, roleRef v1.RoleRef, bindingNamespace string) string {
+	namespace := ""
+	if roleRef.Kind == RoleKind {
+		namespace = bindingNamespace
+	}
+	return NodeID(cluster, roleRef.Kind, namespace, roleRef.Name)
+}
+
+// nodeLabel returns the label of a node, qualified with its namespace when it has one.
+func nodeLabel(kind string, namespace string, name string) string {
+	if namespace == "" {
+		return kind + "-" + name
+	}
+	return kind + "-" + namespace + "/" + name
+}
@@ -0,0 +1,159 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// StartInformers replaces the reads of the app from the cluster with a store fed by shared
+// informers, so that requests are served from memory instead of listing the cluster.
+// The store reports as synced once the informers have listed every object.
+func (app *App) StartInformers(ctx context.Context) {
+	store := NewStore()
+	store.synced.Store(false)
+
+	factory := informers.NewSharedInformerFactoryWithOptions(app.KubeClient, 0, informers.WithTransform(stripManagedFields))
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			store.Add(obj.(runtime.Object))
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			store.Add(obj.(runtime.Object))
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if o, ok := obj.(runtime.Object); ok {
+				store.Delete(o)
+			}
+		},
+	}
+
+	for _, informer := range []cache.SharedIndexInformer{
+		factory.Rbac().V1().ClusterRoleBindings().Informer(),
+		factory.Rbac().V1().RoleBindings().Informer(),
+		factory.Rbac().V1().ClusterRoles().Informer(),
+		factory.Rbac().V1().Roles().Informer(),
+		factory.Core().V1().ServiceAccounts().Informer(),
+		factory.Core().V1().Namespaces().Informer(),
+	} {
+		if _, err := informer.AddEventHandler(handler); err != nil {
+			app.Logger.Error().Err(err).Msg("Failed to add informer event handler")
+		}
+	}
+
+	factory.Start(ctx.Done())
+	app.startWorkloadInformers(ctx, store, handler)
+
+	logger := app.Logger
+	cluster := app.Cluster
+	// OpenShift identities are not watched, they are listed once
+	client := App{KubeClient: app.KubeClient}
+	go func() {
+		for informerType, ok := range factory.WaitForCacheSync(ctx.Done()) {
+			if !ok {
+				logger.Error().Str("cluster", cluster).Str("type", informerType.String()).Msg("Failed to sync informer")
+				return
+			}
+		}
+		identities, err := client.getOpenShiftIdentities()
+		if err != nil {
+			logger.Error().Err(err).Str("cluster", cluster).Msg("Failed to list OpenShift identities")
+		}
+		for _, obj := range identities {
+			store.Add(obj)
+		}
+		store.synced.Store(true)
+		logger.Info().Str("cluster", cluster).Msg("Informer caches synced")
+	}()
+
+	app.Store = store
+}
+
+// startWorkloadInformers watches the workloads with informers of their own, which do not hold back the
+// readiness of the store. Workloads are not needed to read RBAC, so the kinds rbac-wizard is not allowed
+// to list are recorded as unavailable in the store until listing them succeeds.
+func (app *App) startWorkloadInformers(ctx context.Context, store *Store, handler cache.ResourceEventHandler) {
+	factory := informers.NewSharedInformerFactoryWithOptions(app.KubeClient, 0, informers.WithTransform(stripManagedFields))
+
+	logger := app.Logger
+	cluster := app.Cluster
+	for kind, informer := range map[string]cache.SharedIndexInformer{
+		PodKind:         factory.Core().V1().Pods().Informer(),
+		DeploymentKind:  factory.Apps().V1().Deployments().Informer(),
+		StatefulSetKind: factory.Apps().V1().StatefulSets().Informer(),
+		DaemonSetKind:   factory.Apps().V1().DaemonSets().Informer(),
+		JobKind:         factory.Batch().V1().Jobs().Informer(),
+		CronJobKind:     factory.Batch().V1().CronJobs().Informer(),
+	} {
+		if _, err := informer.AddEventHandler(handler); err != nil {
+			logger.Error().Err(err).Msg("Failed to add informer event handler")
+		}
+		err := informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+			if apierrors.IsForbidden(err) {
+				if store.WorkloadsError() == nil {
+					logger.Warn().Err(err).Str("cluster", cluster).Msg("Workloads unavailable")
+				}
+				store.SetListError(kind, err)
+			}
+			cache.DefaultWatchErrorHandler(r, err)
+		})
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to set informer watch error handler")
+		}
+
+		go func() {
+			if cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+				store.SetListError(kind, nil)
+			}
+		}()
+	}
+
+	factory.Start(ctx.Done())
+}
+
+// Ready reports whether the app can serve requests, which is once its store has synced.
+func (app App) Ready() bool {
+	return app.Store == nil || app.Store.Synced()
+}
+
+// stripManagedFields drops the managed fields of the cached objects, and the status of pods, which
+// rbac-wizard does not use but changes often.
+func stripManagedFields(obj interface{}) (interface{}, error) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+	}
+	if pod, ok := obj.(*corev1.Pod); ok {
+		pod.Status = corev1.PodStatus{}
+	}
+	return obj, nil
+}
@@ -0,0 +1,311 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// LeastPrivilege holds the minimal roles and bindings that grant a subject exactly the requests it made.
+// Requests on resources of a namespace are granted by a Role and RoleBinding in that namespace, requests
+// on cluster scoped resources, on resources of every namespace and on non-resource URLs by a ClusterRole
+// and ClusterRoleBinding.
+type LeastPrivilege struct {
+	Subject v1.Subject `json:"subject"`
+	// Requests is the number of allowed requests of the subject the roles are generated from
+	Requests            int                     `json:"requests"`
+	ClusterRoles        []v1.ClusterRole        `json:"clusterRoles,omitempty"`
+	ClusterRoleBindings []v1.ClusterRoleBinding `json:"clusterRoleBindings,omitempty"`
+	Roles               []v1.Role               `json:"roles,omitempty"`
+	RoleBindings        []v1.RoleBinding        `json:"roleBindings,omitempty"`
+	// Changes are the permissions the subject is granted by the generated roles but not by the
+	// current ones, and the current permissions the generated roles leave out
+	Changes PermissionChange `json:"changes"`
+}
+
+// ruleSet collects the verbs of requests by API group and resource, or by non-resource URL.
+type ruleSet struct {
+	resources    map[string]map[string][]string
+	nonResources map[string][]string
+}
+
+// GenerateLeastPrivilege generates the roles and bindings, named after the given name, that grant the
+// subject the allowed requests it made according to the audit events. Groups match the requests of
+// their members. Denied requests are left out, so that the generated roles never widen access.
+func GenerateLeastPrivilege(events []AuditEvent, subject v1.Subject, name string) *LeastPrivilege {
+	lp := &LeastPrivilege{Subject: subject}
+
+	namespaced := map[string]*ruleSet{}
+	cluster := &ruleSet{}
+	for _, event := range events {
+		if event.Denied() || !event.Identity().Matches(subject, "") {
+			continue
+		}
+		lp.Requests++
+
+		if !event.IsResourceRequest() {
+			cluster.addNonResource(event.Path(), event.Verb)
+			continue
+		}
+
+		attrs := event.Attributes()
+		set := cluster
+		if attrs.Namespace != "" {
+			if namespaced[attrs.Namespace] == nil {
+				namespaced[attrs.Namespace] = &ruleSet{}
+			}
+			set = namespaced[attrs.Namespace]
+		}
+		resource := attrs.Resource
+		if attrs.Subresource != "" {
+			resource += "/" + attrs.Subresource
+		}
+		set.addResource(attrs.APIGroup, resource, attrs.Verb)
+	}
+
+	clusterMeta := metav1.ObjectMeta{Name: name}
+	roleRef := v1.RoleRef{APIGroup: v1.GroupName, Name: name}
+	if !cluster.empty() {
+		lp.ClusterRoles = append(lp.ClusterRoles, v1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{Kind: ClusterRoleKind, APIVersion: ClusterRoleAPIVersion},
+			ObjectMeta: clusterMeta,
+			Rules:      cluster.rules(),
+		})
+		roleRef.Kind = ClusterRoleKind
+		lp.ClusterRoleBindings = append(lp.ClusterRoleBindings, v1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{Kind: ClusterRoleBindingKind, APIVersion: ClusterRoleBindingAPIVersion},
+			ObjectMeta: clusterMeta,
+			Subjects:   []v1.Subject{subject},
+			RoleRef:    roleRef,
+		})
+	}
+
+	namespaces := make([]string, 0, len(namespaced))
+	for namespace := range namespaced {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		meta := metav1.ObjectMeta{Name: name, Namespace: namespace}
+		lp.Roles = append(lp.Roles, v1.Role{
+			TypeMeta:   metav1.TypeMeta{Kind: RoleKind, APIVersion: RoleAPIVersion},
+			ObjectMeta: meta,
+			Rules:      namespaced[namespace].rules(),
+		})
+		roleRef.Kind = RoleKind
+		lp.RoleBindings = append(lp.RoleBindings, v1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{Kind: RoleBindingKind, APIVersion: RoleBindingAPIVersion},
+			ObjectMeta: meta,
+			Subjects:   []v1.Subject{subject},
+			RoleRef:    roleRef,
+		})
+	}
+
+	return lp
+}
+
+// Compare sets the changes of the permissions of the identity of the subject from the current roles
+// and bindings to the generated ones.
+func (lp *LeastPrivilege) Compare(bindings *Bindings, roles *Roles, id Identity) {
+	var grants []Grant
+	for _, grant := range ResolveGrants(bindings, roles) {
+		if grant.AppliesTo(id) {
+			grants = append(grants, grant)
+		}
+	}
+
+	suggested := ResolvePermissions(lp.bindings(), lp.roles(), lp.Subject).Permissions
+	needed := map[Permission]struct{}{}
+	lp.Changes = PermissionChange{Subject: lp.Subject}
+	for _, p := range suggested {
+		needed[p] = struct{}{}
+		if !grantsAllow(grants, p) {
+			lp.Changes.Added = append(lp.Changes.Added, p)
+		}
+	}
+	for _, p := range ResolveIdentityPermissions(bindings, roles, lp.Subject, id).Permissions {
+		if _, ok := needed[p]; !ok {
+			lp.Changes.Removed = append(lp.Changes.Removed, p)
+		}
+	}
+}
+
+// Objects returns the generated roles and bindings.
+func (lp *LeastPrivilege) Objects() []runtime.Object {
+	var objects []runtime.Object
+	for i := range lp.ClusterRoles {
+		objects = append(objects, &lp.ClusterRoles[i])
+	}
+	for i := range lp.ClusterRoleBindings {
+		objects = append(objects, &lp.ClusterRoleBindings[i])
+	}
+	for i := range lp.Roles {
+		objects = append(objects, &lp.Roles[i])
+	}
+	for i := range lp.RoleBindings {
+		objects = append(objects, &lp.RoleBindings[i])
+	}
+	return objects
+}
+
+// YAML renders the generated roles and bindings as multi-document YAML.
+func (lp *LeastPrivilege) YAML() ([]byte, error) {
+	var b bytes.Buffer
+	for i, obj := range lp.Objects() {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		unstructured.RemoveNestedField(content, "metadata", "creationTimestamp")
+
+		out, err := yaml.Marshal(content)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			b.WriteString("---\n")
+		}
+		b.Write(out)
+	}
+	return b.Bytes(), nil
+}
+
+func (lp *LeastPrivilege) bindings() *Bindings {
+	return &Bindings{
+		ClusterRoleBindings: &v1.ClusterRoleBindingList{Items: lp.ClusterRoleBindings},
+		RoleBindings:        &v1.RoleBindingList{Items: lp.RoleBindings},
+	}
+}
+
+func (lp *LeastPrivilege) roles() *Roles {
+	return &Roles{
+		ClusterRoles: &v1.ClusterRoleList{Items: lp.ClusterRoles},
+		Roles:        &v1.RoleList{Items: lp.Roles},
+	}
+}
+
+// grantsAllow reports whether any of the grants allows the permission.
+func grantsAllow(grants []Grant, p Permission) bool {
+	request := Request{
+		ResourceAttributes: ResourceAttributes{
+			Verb:        p.Verb,
+			APIGroup:    p.APIGroup,
+			Resource:    p.Resource,
+			Subresource: p.Subresource,
+			Name:        p.ResourceName,
+			Namespace:   p.Namespace,
+		},
+		Path: p.NonResourceURL,
+	}
+	for _, grant := range grants {
+		if grant.Allows(request) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ruleSet) addResource(group string, resource string, verb string) {
+	if s.resources == nil {
+		s.resources = map[string]map[string][]string{}
+	}
+	if s.resources[group] == nil {
+		s.resources[group] = map[string][]string{}
+	}
+	if !contains(s.resources[group][resource], verb) {
+		s.resources[group][resource] = append(s.resources[group][resource], verb)
+	}
+}
+
+func (s *ruleSet) addNonResource(url string, verb string) {
+	if s.nonResources == nil {
+		s.nonResources = map[string][]string{}
+	}
+	if !contains(s.nonResources[url], verb) {
+		s.nonResources[url] = append(s.nonResources[url], verb)
+	}
+}
+
+func (s *ruleSet) empty() bool {
+	return len(s.resources) == 0 && len(s.nonResources) == 0
+}
+
+// rules returns the rules of the set, with one rule for the resources of an API group, or the
+// non-resource URLs, that are requested with the same verbs.
+func (s *ruleSet) rules() []v1.PolicyRule {
+	var rules []v1.PolicyRule
+
+	groups := make([]string, 0, len(s.resources))
+	for group := range s.resources {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		for _, byVerbs := range groupByVerbs(s.resources[group]) {
+			rules = append(rules, v1.PolicyRule{APIGroups: []string{group}, Resources: byVerbs.names, Verbs: byVerbs.verbs})
+		}
+	}
+
+	for _, byVerbs := range groupByVerbs(s.nonResources) {
+		rules = append(rules, v1.PolicyRule{NonResourceURLs: byVerbs.names, Verbs: byVerbs.verbs})
+	}
+
+	return rules
+}
+
+type namesByVerbs struct {
+	verbs []string
+	names []string
+}
+
+// groupByVerbs groups the names that are requested with the same verbs, sorted by the first name.
+func groupByVerbs(verbsByName map[string][]string) []namesByVerbs {
+	names := make([]string, 0, len(verbsByName))
+	for name := range verbsByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var groups []namesByVerbs
+	index := map[string]int{}
+	for _, name := range names {
+		verbs := append([]string(nil), verbsByName[name]...)
+		sort.Strings(verbs)
+		key := strings.Join(verbs, ",")
+		if i, ok := index[key]; ok {
+			groups[i].names = append(groups[i].names, name)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, namesByVerbs{verbs: verbs, names: []string{name}})
+	}
+	return groups
+}
@@ -0,0 +1,197 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// LoadFiles reads the objects of the given YAML or JSON files and directories into a new store.
+// Directories are walked recursively, and objects of kinds the store does not track are skipped,
+// as are documents that are not Kubernetes objects, which are logged at debug level.
+func LoadFiles(paths []string, logger *zerolog.Logger) (*Store, error) {
+	store := NewStore()
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			if err := loadFile(store, path, logger); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !isManifest(p) {
+				return nil
+			}
+			return loadFile(store, p, logger)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+func loadFile(store *Store, path string, logger *zerolog.Logger) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	objects, skipped, err := decodeObjects(content)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+	if skipped > 0 {
+		logger.Debug().Str("file", path).Int("documents", skipped).Msg("Skipped documents without apiVersion or kind")
+	}
+
+	for _, obj := range objects {
+		if store.Add(obj) {
+			store.SetSource(obj, path)
+		}
+	}
+
+	return nil
+}
+
+// DecodeObjects decodes the typed objects of multi-document YAML or JSON content.
+// Items of List kinds, such as the output of "kubectl get -o json", are flattened.
+// Documents without apiVersion or kind, such as Helm values files, are skipped.
+func DecodeObjects(content []byte) ([]runtime.Object, error) {
+	objects, _, err := decodeObjects(content)
+	return objects, err
+}
+
+// decodeObjects decodes the typed objects of the content like DecodeObjects, and also returns the
+// number of documents it skipped.
+func decodeObjects(content []byte) ([]runtime.Object, int, error) {
+	var objects []runtime.Object
+	skipped := 0
+
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+	for {
+		var document interface{}
+		if err := decoder.Decode(&document); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, 0, err
+		}
+		// Empty documents, such as documents of comments only, are not counted
+		if document == nil {
+			continue
+		}
+		raw, ok := document.(map[string]interface{})
+		if !ok || !isObject(raw) {
+			skipped++
+			continue
+		}
+
+		u := &unstructured.Unstructured{Object: raw}
+		if u.IsList() {
+			list, err := u.ToList()
+			if err != nil {
+				return nil, 0, err
+			}
+			for i := range list.Items {
+				if !isObject(list.Items[i].Object) {
+					skipped++
+					continue
+				}
+				obj, err := toTyped(&list.Items[i])
+				if err != nil {
+					return nil, 0, err
+				}
+				objects = append(objects, obj)
+			}
+			continue
+		}
+
+		obj, err := toTyped(u)
+		if err != nil {
+			return nil, 0, err
+		}
+		objects = append(objects, obj)
+	}
+
+	return objects, skipped, nil
+}
+
+// isObject reports whether a decoded document is a Kubernetes object, with an apiVersion and a kind.
+func isObject(raw map[string]interface{}) bool {
+	apiVersion, _ := raw["apiVersion"].(string)
+	kind, _ := raw["kind"].(string)
+	return apiVersion != "" && kind != ""
+}
+
+// toTyped converts an unstructured object to its typed form. Kinds unknown
+// to the client scheme are returned as unstructured objects.
+func toTyped(u *unstructured.Unstructured) (runtime.Object, error) {
+	gvk := u.GroupVersionKind()
+	if gvk.Kind == "" {
+		return nil, fmt.Errorf("object has no kind")
+	}
+
+	obj, err := scheme.Scheme.New(gvk)
+	if err != nil {
+		return u, nil
+	}
+
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), obj); err != nil {
+		return nil, fmt.Errorf("failed to convert %s %s: %v", gvk.Kind, u.GetName(), err)
+	}
+
+	return obj, nil
+}
+
+func isManifest(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
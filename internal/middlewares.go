@@ -57,3 +57,13 @@ func (rw *responseWriter) WriteHeader(statusCode int) {
 		rw.wroteHeader = true
 	}
 }
+
+// Flush implements http.Flusher so that streaming responses can pass through the middleware.
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.w.(http.Flusher); ok {
+		if !rw.wroteHeader {
+			rw.WriteHeader(http.StatusOK)
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,298 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/rbac/v1"
+)
+
+const (
+	AllAuthenticatedGroup   = "system:authenticated"
+	AllUnauthenticatedGroup = "system:unauthenticated"
+	AnonymousUser           = "system:anonymous"
+	ServiceAccountsGroup    = "system:serviceaccounts"
+	ServiceAccountPrefix    = "system:serviceaccount:"
+)
+
+// Identity is the user name and the groups a request is authenticated as.
+type Identity struct {
+	User   string   `json:"user,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// BindingRef identifies the binding a grant comes from.
+type BindingRef struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Grant is a single policy rule that a binding grants to its subjects.
+// An empty namespace means the rule applies cluster wide.
+type Grant struct {
+	Binding   BindingRef    `json:"binding"`
+	RoleRef   v1.RoleRef    `json:"roleRef"`
+	Namespace string        `json:"namespace,omitempty"`
+	Subjects  []v1.Subject  `json:"subjects"`
+	Rule      v1.PolicyRule `json:"rule"`
+	// AggregatedFrom is the ClusterRole the rule is aggregated from when the role is an aggregated ClusterRole
+	AggregatedFrom string `json:"aggregatedFrom,omitempty"`
+}
+
+// Permission is a single effective permission of a subject.
+type Permission struct {
+	APIGroup       string `json:"apiGroup"`
+	Resource       string `json:"resource,omitempty"`
+	Subresource    string `json:"subresource,omitempty"`
+	ResourceName   string `json:"resourceName,omitempty"`
+	NonResourceURL string `json:"nonResourceURL,omitempty"`
+	Verb           string `json:"verb"`
+	Namespace      string `json:"namespace,omitempty"`
+}
+
+type SubjectPermissions struct {
+	Cluster     string       `json:"cluster,omitempty"`
+	Subject     v1.Subject   `json:"subject"`
+	Permissions []Permission `json:"permissions"`
+	// Groups are the groups of identity sources the subject is a member of
+	Groups []string `json:"groups,omitempty"`
+}
+
+// NewSubject builds a subject from a case-insensitive kind, a namespace and a name.
+func NewSubject(kind string, namespace string, name string) (v1.Subject, error) {
+	if name == "" {
+		return v1.Subject{}, fmt.Errorf("subject name is required")
+	}
+
+	switch strings.ToLower(kind) {
+	case "user":
+		return v1.Subject{Kind: v1.UserKind, APIGroup: v1.GroupName, Name: name}, nil
+	case "group":
+		return v1.Subject{Kind: v1.GroupKind, APIGroup: v1.GroupName, Name: name}, nil
+	case "serviceaccount", "sa":
+		if namespace == "" {
+			return v1.Subject{}, fmt.Errorf("namespace is required for service accounts")
+		}
+		return v1.Subject{Kind: v1.ServiceAccountKind, Namespace: namespace, Name: name}, nil
+	}
+
+	return v1.Subject{}, fmt.Errorf("unsupported subject kind %q, must be one of User, Group or ServiceAccount", kind)
+}
+
+// IdentityFor returns the identity a subject authenticates as, including the
+// groups Kubernetes implicitly adds to users and service accounts.
+func IdentityFor(subject v1.Subject) Identity {
+	switch subject.Kind {
+	case v1.ServiceAccountKind:
+		return Identity{
+			User:   ServiceAccountPrefix + subject.Namespace + ":" + subject.Name,
+			Groups: []string{ServiceAccountsGroup, ServiceAccountsGroup + ":" + subject.Namespace, AllAuthenticatedGroup},
+		}
+	case v1.UserKind:
+		if subject.Name == AnonymousUser {
+			return Identity{User: subject.Name, Groups: []string{AllUnauthenticatedGroup}}
+		}
+		return Identity{User: subject.Name, Groups: []string{AllAuthenticatedGroup}}
+	case v1.GroupKind:
+		return Identity{Groups: []string{subject.Name}}
+	}
+
+	return Identity{}
+}
+
+// Matches reports whether a binding subject in the given namespace refers to the identity.
+func (id Identity) Matches(subject v1.Subject, namespace string) bool {
+	switch subject.Kind {
+	case v1.UserKind:
+		return id.User != "" && id.User == subject.Name
+	case v1.GroupKind:
+		for _, group := range id.Groups {
+			if group == subject.Name {
+				return true
+			}
+		}
+	case v1.ServiceAccountKind:
+		// Service account subjects of RoleBindings default to the namespace of the binding
+		saNamespace := namespace
+		if subject.Namespace != "" {
+			saNamespace = subject.Namespace
+		}
+		if saNamespace == "" {
+			return false
+		}
+		return id.User == ServiceAccountPrefix+saNamespace+":"+subject.Name
+	}
+
+	return false
+}
+
+// AppliesTo reports whether any of the subjects of the grant refers to the identity.
+func (g Grant) AppliesTo(id Identity) bool {
+	for _, subject := range g.Subjects {
+		if id.Matches(subject, g.Binding.Namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveGrants walks every binding and returns the rules of the roles they reference.
+// Bindings that reference roles which do not exist grant nothing.
+func ResolveGrants(bindings *Bindings, roles *Roles) []Grant {
+	var grants []Grant
+	index := newRoleIndex(roles)
+
+	if bindings.ClusterRoleBindings != nil {
+		for _, crb := range bindings.ClusterRoleBindings.Items {
+			rules, _ := index.aggregatedRules(crb.RoleRef, "")
+			ref := BindingRef{Kind: ClusterRoleBindingKind, Name: crb.Name}
+			for _, rule := range rules {
+				grants = append(grants, Grant{
+					Binding:        ref,
+					RoleRef:        crb.RoleRef,
+					Subjects:       crb.Subjects,
+					Rule:           rule.Rule,
+					AggregatedFrom: aggregatedFrom(crb.RoleRef, rule),
+				})
+			}
+		}
+	}
+
+	if bindings.RoleBindings != nil {
+		for _, rb := range bindings.RoleBindings.Items {
+			rules, _ := index.aggregatedRules(rb.RoleRef, rb.Namespace)
+			ref := BindingRef{Kind: RoleBindingKind, Name: rb.Name, Namespace: rb.Namespace}
+			for _, rule := range rules {
+				grants = append(grants, Grant{
+					Binding:        ref,
+					RoleRef:        rb.RoleRef,
+					Namespace:      rb.Namespace,
+					Subjects:       rb.Subjects,
+					Rule:           rule.Rule,
+					AggregatedFrom: aggregatedFrom(rb.RoleRef, rule),
+				})
+			}
+		}
+	}
+
+	return grants
+}
+
+// aggregatedFrom returns the component ClusterRole a rule of the referenced role is aggregated from, if any.
+func aggregatedFrom(roleRef v1.RoleRef, rule AggregatedRule) string {
+	if rule.ClusterRole == "" || rule.ClusterRole == roleRef.Name {
+		return ""
+	}
+	return rule.ClusterRole
+}
+
+// ResolvePermissions returns the merged set of permissions the subject is granted by all bindings.
+func ResolvePermissions(bindings *Bindings, roles *Roles, subject v1.Subject) SubjectPermissions {
+	return ResolveIdentityPermissions(bindings, roles, subject, IdentityFor(subject))
+}
+
+// ResolveIdentityPermissions returns the merged set of permissions granted to the identity the subject
+// authenticates as, which can have groups that no binding of the cluster knows the subject is a member of.
+func ResolveIdentityPermissions(bindings *Bindings, roles *Roles, subject v1.Subject, id Identity) SubjectPermissions {
+	seen := map[Permission]struct{}{}
+	for _, grant := range ResolveGrants(bindings, roles) {
+		if !grant.AppliesTo(id) {
+			continue
+		}
+		for _, p := range expandRule(grant.Rule, grant.Namespace) {
+			seen[p] = struct{}{}
+		}
+	}
+
+	permissions := make([]Permission, 0, len(seen))
+	for p := range seen {
+		permissions = append(permissions, p)
+	}
+	sortPermissions(permissions)
+
+	return SubjectPermissions{
+		Subject:     subject,
+		Permissions: permissions,
+	}
+}
+
+// expandRule flattens a policy rule into one permission per verb, API group, resource and resource name.
+func expandRule(rule v1.PolicyRule, namespace string) []Permission {
+	var permissions []Permission
+
+	names := rule.ResourceNames
+	if len(names) == 0 {
+		names = []string{""}
+	}
+
+	for _, verb := range rule.Verbs {
+		// Non-resource URLs are not namespaced and only take effect through ClusterRoleBindings
+		if namespace == "" {
+			for _, url := range rule.NonResourceURLs {
+				permissions = append(permissions, Permission{NonResourceURL: url, Verb: verb})
+			}
+		}
+
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				resource, subresource, _ := strings.Cut(resource, "/")
+				for _, name := range names {
+					permissions = append(permissions, Permission{
+						APIGroup:     group,
+						Resource:     resource,
+						Subresource:  subresource,
+						ResourceName: name,
+						Verb:         verb,
+						Namespace:    namespace,
+					})
+				}
+			}
+		}
+	}
+
+	return permissions
+}
+
+func sortPermissions(permissions []Permission) {
+	sort.Slice(permissions, func(i, j int) bool {
+		a, b := permissions[i], permissions[j]
+		for _, pair := range [][2]string{
+			{a.Namespace, b.Namespace},
+			{a.NonResourceURL, b.NonResourceURL},
+			{a.APIGroup, b.APIGroup},
+			{a.Resource, b.Resource},
+			{a.Subresource, b.Subresource},
+			{a.ResourceName, b.ResourceName},
+			{a.Verb, b.Verb},
+		} {
+			if pair[0] != pair[1] {
+				return pair[0] < pair[1]
+			}
+		}
+		return false
+	})
+}
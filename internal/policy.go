@@ -0,0 +1,277 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"sigs.k8s.io/yaml"
+)
+
+// Policy is a user-defined check written as a CEL expression. The expression is evaluated once for every bound
+// subject, with the variables:
+//
+//   - subject: the kind, name and namespace of the subject
+//   - permissions: the effective permissions of the subject, with apiGroup, resource, subresource, resourceName,
+//     nonResourceURL, verb and namespace
+//   - grants: the rules granted to the subject, with the binding (kind, name, namespace), the roleRef (kind, name),
+//     the namespace they apply to and the rule (verbs, apiGroups, resources, resourceNames, nonResourceURLs)
+//
+// The subject violates the policy when the expression is true. The optional evidence expression returns a string
+// describing the violation.
+type Policy struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+	Remediation string `json:"remediation"`
+	Expression  string `json:"expression"`
+	Evidence    string `json:"evidence,omitempty"`
+
+	program  cel.Program
+	evidence cel.Program
+}
+
+// policyFile is the format of policy files.
+type policyFile struct {
+	Policies []Policy `json:"policies"`
+}
+
+// LoadPolicies reads and compiles the policies of the YAML files of a directory.
+func LoadPolicies(dir string) ([]Policy, error) {
+	env, err := policyEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var policies []Policy
+	seen := map[string]string{}
+
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || (filepath.Ext(path) != ".yaml" && filepath.Ext(path) != ".yml") {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var file policyFile
+		if err := yaml.UnmarshalStrict(content, &file); err != nil {
+			return fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+
+		for _, policy := range file.Policies {
+			if previous, ok := seen[policy.ID]; ok {
+				return fmt.Errorf("policy %s of %s is already defined in %s", policy.ID, path, previous)
+			}
+			seen[policy.ID] = path
+
+			if err := policy.compile(env); err != nil {
+				return fmt.Errorf("invalid policy %s in %s: %v", policy.ID, path, err)
+			}
+			policies = append(policies, policy)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+func policyEnv() (*cel.Env, error) {
+	object := cel.MapType(cel.StringType, cel.DynType)
+	return cel.NewEnv(
+		cel.Variable("subject", object),
+		cel.Variable("permissions", cel.ListType(object)),
+		cel.Variable("grants", cel.ListType(object)),
+		ext.Strings(),
+	)
+}
+
+func (p *Policy) compile(env *cel.Env) error {
+	switch {
+	case p.ID == "":
+		return fmt.Errorf("id is required")
+	case p.Title == "":
+		return fmt.Errorf("title is required")
+	case !ValidSeverity(p.Severity):
+		return fmt.Errorf("invalid severity %q, must be one of critical, high, medium or low", p.Severity)
+	case p.Expression == "":
+		return fmt.Errorf("expression is required")
+	}
+
+	program, err := compileExpression(env, p.Expression, cel.BoolType)
+	if err != nil {
+		return fmt.Errorf("expression: %v", err)
+	}
+	p.program = program
+
+	if p.Evidence != "" {
+		evidence, err := compileExpression(env, p.Evidence, cel.StringType)
+		if err != nil {
+			return fmt.Errorf("evidence: %v", err)
+		}
+		p.evidence = evidence
+	}
+
+	return nil
+}
+
+func compileExpression(env *cel.Env, expression string, output *cel.Type) (cel.Program, error) {
+	ast, issues := env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if !ast.OutputType().IsExactType(output) && !ast.OutputType().IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("must return %s, not %s", output, ast.OutputType())
+	}
+	return env.Program(ast)
+}
+
+// Check returns the policy as a check of the catalogue, for reports.
+func (p Policy) Check() Check {
+	return Check{
+		ID:          p.ID,
+		Title:       p.Title,
+		Severity:    p.Severity,
+		Description: p.Description,
+		Remediation: p.Remediation,
+	}
+}
+
+// EvaluatePolicies evaluates the policies for every bound subject and returns their violations as findings.
+func EvaluatePolicies(policies []Policy, bindings *Bindings, roles *Roles, cluster string) ([]Finding, error) {
+	findings := []Finding{}
+	if len(policies) == 0 {
+		return findings, nil
+	}
+
+	grants := ResolveGrants(bindings, roles)
+	for _, subject := range boundSubjects(bindings) {
+		id := IdentityFor(subject)
+
+		var subjectGrants []interface{}
+		for _, grant := range grants {
+			if grant.AppliesTo(id) {
+				subjectGrants = append(subjectGrants, grantValue(grant))
+			}
+		}
+		var permissions []interface{}
+		for _, p := range ResolvePermissions(bindings, roles, subject).Permissions {
+			permissions = append(permissions, permissionValue(p))
+		}
+
+		vars := map[string]interface{}{
+			"subject":     map[string]interface{}{"kind": subject.Kind, "name": subject.Name, "namespace": subject.Namespace},
+			"permissions": listValue(permissions),
+			"grants":      listValue(subjectGrants),
+		}
+
+		for _, policy := range policies {
+			out, _, err := policy.program.Eval(vars)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate policy %s for %s %s: %v", policy.ID, subject.Kind, qualifiedName(subject), err)
+			}
+			if violated, ok := out.Value().(bool); !ok || !violated {
+				continue
+			}
+
+			evidence := subject.Kind + " " + qualifiedName(subject) + " violates the policy"
+			if policy.evidence != nil {
+				out, _, err := policy.evidence.Eval(vars)
+				if err != nil {
+					return nil, fmt.Errorf("failed to evaluate the evidence of policy %s for %s %s: %v", policy.ID, subject.Kind, qualifiedName(subject), err)
+				}
+				evidence = fmt.Sprint(out.Value())
+			}
+
+			s := subject
+			findings = append(findings, Finding{
+				Cluster:     cluster,
+				CheckID:     policy.ID,
+				Title:       policy.Title,
+				Severity:    policy.Severity,
+				Description: policy.Description,
+				Remediation: policy.Remediation,
+				NodeID:      SubjectID(cluster, subject, ""),
+				Subject:     &s,
+				Evidence:    evidence,
+			})
+		}
+	}
+
+	SortFindings(findings)
+	return findings, nil
+}
+
+func grantValue(grant Grant) map[string]interface{} {
+	return map[string]interface{}{
+		"binding":   map[string]interface{}{"kind": grant.Binding.Kind, "name": grant.Binding.Name, "namespace": grant.Binding.Namespace},
+		"roleRef":   map[string]interface{}{"kind": grant.RoleRef.Kind, "name": grant.RoleRef.Name},
+		"namespace": grant.Namespace,
+		"rule": map[string]interface{}{
+			"verbs":           stringList(grant.Rule.Verbs),
+			"apiGroups":       stringList(grant.Rule.APIGroups),
+			"resources":       stringList(grant.Rule.Resources),
+			"resourceNames":   stringList(grant.Rule.ResourceNames),
+			"nonResourceURLs": stringList(grant.Rule.NonResourceURLs),
+		},
+	}
+}
+
+func permissionValue(p Permission) map[string]interface{} {
+	return map[string]interface{}{
+		"apiGroup":       p.APIGroup,
+		"resource":       p.Resource,
+		"subresource":    p.Subresource,
+		"resourceName":   p.ResourceName,
+		"nonResourceURL": p.NonResourceURL,
+		"verb":           p.Verb,
+		"namespace":      p.Namespace,
+	}
+}
+
+// stringList returns the values as a list CEL can compare, never nil.
+func stringList(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func listValue(values []interface{}) []interface{} {
+	if values == nil {
+		return []interface{}{}
+	}
+	return values
+}
@@ -0,0 +1,190 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ClusterRoleKind       = "ClusterRole"
+	RoleKind              = "Role"
+	ClusterRoleAPIVersion = "rbac.authorization.k8s.io/v1"
+	RoleAPIVersion        = "rbac.authorization.k8s.io/v1"
+)
+
+func (app App) GetRoles() (*Roles, error) {
+	if app.Store != nil {
+		return app.Store.Roles(), nil
+	}
+
+	clientset := app.KubeClient
+
+	crs, err := clientset.RbacV1().ClusterRoles().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		fmt.Println("Error listing cluster roles:", err)
+		return nil, err
+	}
+
+	rs, err := clientset.RbacV1().Roles("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		fmt.Println("Error listing roles:", err)
+		return nil, err
+	}
+
+	return &Roles{
+		ClusterRoles: crs,
+		Roles:        rs,
+	}, nil
+}
+
+func (app App) GetClusterRole(name string) (*v1.ClusterRole, error) {
+	if app.Store != nil {
+		obj, ok := app.Store.Get(ClusterRoleKind, "", name)
+		if !ok {
+			return nil, apierrors.NewNotFound(v1.Resource("clusterroles"), name)
+		}
+		return obj.(*v1.ClusterRole), nil
+	}
+
+	return app.KubeClient.RbacV1().ClusterRoles().Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+// GenerateRoleData returns the Roles and ClusterRoles with the rules they grant.
+func GenerateRoleData(roles *Roles, cluster string) []RoleData {
+	data := []RoleData{}
+	if roles == nil {
+		return data
+	}
+
+	if roles.ClusterRoles != nil {
+		aggregated := AggregateClusterRoles(roles.ClusterRoles.Items)
+		aggregates := aggregationIndex(roles.ClusterRoles.Items)
+		for _, cr := range roles.ClusterRoles.Items {
+			cr.ManagedFields = nil
+
+			rules := make([]v1.PolicyRule, 0, len(aggregated[cr.Name]))
+			for _, rule := range aggregated[cr.Name] {
+				rules = append(rules, rule.Rule)
+			}
+
+			data = append(data, RoleData{
+				Id:              NodeID(cluster, ClusterRoleKind, "", cr.Name),
+				Cluster:         cluster,
+				Name:            cr.Name,
+				Kind:            ClusterRoleKind,
+				Labels:          cr.Labels,
+				Annotations:     cr.Annotations,
+				Rules:           rules,
+				AggregationRule: cr.AggregationRule,
+				Components:      roleComponents(cluster, cr.Name, aggregated[cr.Name]),
+				AggregatedInto:  aggregatesOf(cr.Name, aggregates),
+				Raw:             yamlParser(&cr, ClusterRoleKind, ClusterRoleAPIVersion),
+			})
+		}
+	}
+
+	if roles.Roles != nil {
+		for _, r := range roles.Roles.Items {
+			r.ManagedFields = nil
+
+			rules := r.Rules
+			if rules == nil {
+				rules = []v1.PolicyRule{}
+			}
+
+			data = append(data, RoleData{
+				Id:          NodeID(cluster, RoleKind, r.Namespace, r.Name),
+				Cluster:     cluster,
+				Name:        r.Name,
+				Namespace:   r.Namespace,
+				Kind:        RoleKind,
+				Labels:      r.Labels,
+				Annotations: r.Annotations,
+				Rules:       rules,
+				Raw:         yamlParser(&r, RoleKind, RoleAPIVersion),
+			})
+		}
+	}
+
+	return data
+}
+
+// roleIndex looks up the rules of a role by its kind, namespace and name. The rules of aggregated
+// ClusterRoles are resolved from their component ClusterRoles.
+type roleIndex map[string][]AggregatedRule
+
+func newRoleIndex(roles *Roles) roleIndex {
+	index := roleIndex{}
+	if roles == nil {
+		return index
+	}
+
+	if roles.ClusterRoles != nil {
+		for name, rules := range AggregateClusterRoles(roles.ClusterRoles.Items) {
+			index[roleKey(ClusterRoleKind, "", name)] = rules
+		}
+	}
+
+	if roles.Roles != nil {
+		for _, r := range roles.Roles.Items {
+			rules := make([]AggregatedRule, 0, len(r.Rules))
+			for _, rule := range r.Rules {
+				rules = append(rules, AggregatedRule{Rule: rule})
+			}
+			index[roleKey(RoleKind, r.Namespace, r.Name)] = rules
+		}
+	}
+
+	return index
+}
+
+// rules returns the rules of the role referenced by a binding in the given namespace.
+// ClusterRoles are cluster scoped, so the namespace is only used for Roles.
+func (i roleIndex) rules(roleRef v1.RoleRef, namespace string) ([]v1.PolicyRule, bool) {
+	aggregated, ok := i.aggregatedRules(roleRef, namespace)
+	rules := make([]v1.PolicyRule, 0, len(aggregated))
+	for _, rule := range aggregated {
+		rules = append(rules, rule.Rule)
+	}
+	return rules, ok
+}
+
+// aggregatedRules returns the rules of the role referenced by a binding in the given namespace,
+// with the ClusterRole each of them is aggregated from.
+func (i roleIndex) aggregatedRules(roleRef v1.RoleRef, namespace string) ([]AggregatedRule, bool) {
+	if roleRef.Kind == ClusterRoleKind {
+		namespace = ""
+	}
+	rules, ok := i[roleKey(roleRef.Kind, namespace, roleRef.Name)]
+	return rules, ok
+}
+
+func roleKey(kind string, namespace string, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
@@ -0,0 +1,58 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (app App) GetServiceAccount(namespace string, name string) (*corev1.ServiceAccount, error) {
+	if app.Store != nil {
+		obj, ok := app.Store.Get(ServiceAccountKind, namespace, name)
+		if !ok {
+			return nil, apierrors.NewNotFound(corev1.Resource("serviceaccounts"), name)
+		}
+		return obj.(*corev1.ServiceAccount), nil
+	}
+
+	return app.KubeClient.CoreV1().ServiceAccounts(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+func (app App) GetServiceAccounts() (*corev1.ServiceAccountList, error) {
+	if app.Store != nil {
+		return app.Store.ServiceAccounts(), nil
+	}
+
+	sas, err := app.KubeClient.CoreV1().ServiceAccounts("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		fmt.Println("Error listing service accounts:", err)
+		return nil, err
+	}
+
+	return sas, nil
+}
@@ -0,0 +1,266 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const snapshotMetadataFile = "metadata.json"
+
+// snapshotFiles maps the kinds captured in a snapshot to the files they are archived in.
+var snapshotFiles = []struct {
+	Kind string
+	File string
+}{
+	{ClusterRoleBindingKind, "clusterrolebindings.json"},
+	{RoleBindingKind, "rolebindings.json"},
+	{ClusterRoleKind, "clusterroles.json"},
+	{RoleKind, "roles.json"},
+	{ServiceAccountKind, "serviceaccounts.json"},
+	{NamespaceKind, "namespaces.json"},
+	{PodKind, "pods.json"},
+	{DeploymentKind, "deployments.json"},
+	{StatefulSetKind, "statefulsets.json"},
+	{DaemonSetKind, "daemonsets.json"},
+	{JobKind, "jobs.json"},
+	{CronJobKind, "cronjobs.json"},
+	{OpenShiftGroupKind, "openshift-groups.json"},
+	{OpenShiftUserKind, "openshift-users.json"},
+}
+
+type SnapshotMetadata struct {
+	Context           string    `json:"context,omitempty"`
+	Server            string    `json:"server,omitempty"`
+	KubernetesVersion string    `json:"kubernetesVersion,omitempty"`
+	CreatedAt         time.Time `json:"createdAt"`
+	ToolVersion       string    `json:"toolVersion,omitempty"`
+}
+
+// Snapshot is a portable copy of the objects rbac-wizard reads from a cluster.
+type Snapshot struct {
+	Metadata SnapshotMetadata
+	Store    *Store
+}
+
+// TakeSnapshot captures every object the app reads into a snapshot.
+func (app App) TakeSnapshot(metadata SnapshotMetadata) (*Snapshot, error) {
+	store := NewStore()
+
+	bindings, err := app.GetBindings()
+	if err != nil {
+		return nil, err
+	}
+	for i := range bindings.ClusterRoleBindings.Items {
+		store.Add(&bindings.ClusterRoleBindings.Items[i])
+	}
+	for i := range bindings.RoleBindings.Items {
+		store.Add(&bindings.RoleBindings.Items[i])
+	}
+
+	roles, err := app.GetRoles()
+	if err != nil {
+		return nil, err
+	}
+	for i := range roles.ClusterRoles.Items {
+		store.Add(&roles.ClusterRoles.Items[i])
+	}
+	for i := range roles.Roles.Items {
+		store.Add(&roles.Roles.Items[i])
+	}
+
+	sas, err := app.GetServiceAccounts()
+	if err != nil {
+		return nil, err
+	}
+	for i := range sas.Items {
+		store.Add(&sas.Items[i])
+	}
+
+	namespaces, err := app.GetNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	for i := range namespaces.Items {
+		store.Add(&namespaces.Items[i])
+	}
+
+	// Snapshots are still taken without the workloads that cannot be listed
+	workloads, err := app.GetWorkloads()
+	if err != nil && app.Logger != nil {
+		app.Logger.Warn().Err(err).Str("cluster", app.Cluster).Msg("Snapshot is taken without some workloads")
+	}
+	for _, obj := range workloads {
+		store.Add(obj)
+	}
+
+	identities, err := app.getOpenShiftIdentities()
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range identities {
+		store.Add(obj)
+	}
+
+	if app.KubeClient != nil && metadata.KubernetesVersion == "" {
+		if version, err := app.KubeClient.Discovery().ServerVersion(); err == nil {
+			metadata.KubernetesVersion = version.GitVersion
+		}
+	}
+	if metadata.CreatedAt.IsZero() {
+		metadata.CreatedAt = time.Now().UTC()
+	}
+
+	return &Snapshot{Metadata: metadata, Store: store}, nil
+}
+
+// Write archives the snapshot as a gzipped tarball, with the metadata and
+// one List file per kind that can also be read with the file loader.
+func (s *Snapshot) Write(w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	metadata, err := json.MarshalIndent(s.Metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, snapshotMetadataFile, metadata, s.Metadata.CreatedAt); err != nil {
+		return err
+	}
+
+	for _, f := range snapshotFiles {
+		list := &metav1.List{TypeMeta: metav1.TypeMeta{Kind: "List", APIVersion: "v1"}}
+		for _, obj := range s.Store.List(f.Kind) {
+			obj = obj.DeepCopyObject()
+			if err := setTypeMeta(obj); err != nil {
+				return err
+			}
+			list.Items = append(list.Items, runtime.RawExtension{Object: obj})
+		}
+
+		content, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, f.File, content, s.Metadata.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// LoadSnapshot reads a snapshot written by Snapshot.Write.
+func LoadSnapshot(file string) (*Snapshot, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadSnapshot(f)
+}
+
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+	defer gr.Close()
+
+	snapshot := &Snapshot{Store: NewStore()}
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from snapshot: %v", header.Name, err)
+		}
+
+		if path.Base(header.Name) == snapshotMetadataFile {
+			if err := json.Unmarshal(content, &snapshot.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to decode snapshot metadata: %v", err)
+			}
+			continue
+		}
+
+		objects, err := DecodeObjects(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s from snapshot: %v", header.Name, err)
+		}
+		for _, obj := range objects {
+			snapshot.Store.Add(obj)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// setTypeMeta sets the kind and API version of a typed object, which are
+// empty on objects returned by list calls.
+func setTypeMeta(obj runtime.Object) error {
+	gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvks[0])
+	return nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, content []byte, modTime time.Time) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(content)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(content)
+	return err
+}
@@ -0,0 +1,299 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	ServiceAccountKind = "ServiceAccount"
+	NamespaceKind      = "Namespace"
+)
+
+// Store is an in-memory store of the objects rbac-wizard reads from a cluster,
+// indexed by kind and by namespace and name. It is safe for concurrent use.
+type Store struct {
+	mu        sync.RWMutex
+	objects   map[string]map[string]runtime.Object
+	sources   map[string]string
+	listeners []StoreListener
+	// listErrors are the errors of the workload kinds that could not be listed, by kind
+	listErrors map[string]error
+	// synced is false while the store is being filled by informers
+	synced atomic.Bool
+}
+
+// StoreListener is called with the event type and the object after every change of a store.
+type StoreListener func(eventType string, obj runtime.Object)
+
+func NewStore() *Store {
+	s := &Store{objects: map[string]map[string]runtime.Object{}, sources: map[string]string{}, listErrors: map[string]error{}}
+	s.synced.Store(true)
+	return s
+}
+
+// Synced reports whether the store holds every object of its source.
+func (s *Store) Synced() bool {
+	return s.synced.Load()
+}
+
+// AddListener registers a listener that is notified of every later change of the store.
+func (s *Store) AddListener(listener StoreListener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listeners = append(s.listeners, listener)
+}
+
+// SetListError records that the workloads of a kind could not be listed, or clears it when err is nil.
+func (s *Store) SetListError(kind string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err == nil {
+		delete(s.listErrors, kind)
+		return
+	}
+	s.listErrors[kind] = err
+}
+
+// WorkloadsError returns the errors of the workload kinds that could not be listed, if any.
+func (s *Store) WorkloadsError() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var errs []error
+	for _, kind := range workloadKinds {
+		if err, ok := s.listErrors[kind]; ok {
+			errs = append(errs, err)
+		}
+	}
+	return workloadsError(errs)
+}
+
+// Add adds or replaces an object in the store, with its managed fields stripped. Workloads are
+// slimmed down to what rbac-wizard uses. It reports false if the kind of the object is not tracked by the store.
+func (s *Store) Add(obj runtime.Object) bool {
+	kind := storeKind(obj)
+	if kind == "" {
+		return false
+	}
+
+	obj = slimWorkload(obj.DeepCopyObject())
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	accessor.SetManagedFields(nil)
+
+	s.mu.Lock()
+	if s.objects[kind] == nil {
+		s.objects[kind] = map[string]runtime.Object{}
+	}
+	key := objectKey(accessor.GetNamespace(), accessor.GetName())
+	_, exists := s.objects[kind][key]
+	s.objects[kind][key] = obj
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	eventType := EventAdded
+	if exists {
+		eventType = EventModified
+	}
+	for _, listener := range listeners {
+		listener(eventType, obj)
+	}
+
+	return true
+}
+
+// Delete removes an object from the store.
+func (s *Store) Delete(obj runtime.Object) {
+	kind := storeKind(obj)
+	accessor, err := meta.Accessor(obj)
+	if kind == "" || err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	key := objectKey(accessor.GetNamespace(), accessor.GetName())
+	stored, exists := s.objects[kind][key]
+	delete(s.objects[kind], key)
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	if !exists {
+		return
+	}
+	for _, listener := range listeners {
+		listener(EventDeleted, stored)
+	}
+}
+
+// SetSource records the file an object was loaded from.
+func (s *Store) SetSource(obj runtime.Object, source string) {
+	kind := storeKind(obj)
+	accessor, err := meta.Accessor(obj)
+	if kind == "" || err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sources[kind+"/"+objectKey(accessor.GetNamespace(), accessor.GetName())] = source
+}
+
+// Source returns the file the object of the given kind, namespace and name was loaded from, if any.
+func (s *Store) Source(kind string, namespace string, name string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sources[kind+"/"+objectKey(namespace, name)]
+}
+
+// Get returns the object of the given kind, namespace and name.
+func (s *Store) Get(kind string, namespace string, name string) (runtime.Object, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	obj, ok := s.objects[kind][objectKey(namespace, name)]
+	return obj, ok
+}
+
+// List returns the objects of the given kind sorted by namespace and name.
+func (s *Store) List(kind string) []runtime.Object {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.objects[kind]))
+	for key := range s.objects[kind] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	objects := make([]runtime.Object, 0, len(keys))
+	for _, key := range keys {
+		objects = append(objects, s.objects[kind][key])
+	}
+	return objects
+}
+
+func (s *Store) Bindings() *Bindings {
+	bindings := &Bindings{
+		ClusterRoleBindings: &v1.ClusterRoleBindingList{},
+		RoleBindings:        &v1.RoleBindingList{},
+	}
+
+	for _, obj := range s.List(ClusterRoleBindingKind) {
+		bindings.ClusterRoleBindings.Items = append(bindings.ClusterRoleBindings.Items, *obj.(*v1.ClusterRoleBinding))
+	}
+	for _, obj := range s.List(RoleBindingKind) {
+		bindings.RoleBindings.Items = append(bindings.RoleBindings.Items, *obj.(*v1.RoleBinding))
+	}
+
+	return bindings
+}
+
+func (s *Store) Roles() *Roles {
+	roles := &Roles{
+		ClusterRoles: &v1.ClusterRoleList{},
+		Roles:        &v1.RoleList{},
+	}
+
+	for _, obj := range s.List(ClusterRoleKind) {
+		roles.ClusterRoles.Items = append(roles.ClusterRoles.Items, *obj.(*v1.ClusterRole))
+	}
+	for _, obj := range s.List(RoleKind) {
+		roles.Roles.Items = append(roles.Roles.Items, *obj.(*v1.Role))
+	}
+
+	return roles
+}
+
+func (s *Store) ServiceAccounts() *corev1.ServiceAccountList {
+	list := &corev1.ServiceAccountList{}
+	for _, obj := range s.List(ServiceAccountKind) {
+		list.Items = append(list.Items, *obj.(*corev1.ServiceAccount))
+	}
+	return list
+}
+
+func (s *Store) Namespaces() *corev1.NamespaceList {
+	list := &corev1.NamespaceList{}
+	for _, obj := range s.List(NamespaceKind) {
+		list.Items = append(list.Items, *obj.(*corev1.Namespace))
+	}
+	return list
+}
+
+// storeKind returns the kind the store tracks the object as, or an empty string if it is not tracked.
+func storeKind(obj runtime.Object) string {
+	switch o := obj.(type) {
+	case *v1.ClusterRoleBinding:
+		return ClusterRoleBindingKind
+	case *v1.RoleBinding:
+		return RoleBindingKind
+	case *v1.ClusterRole:
+		return ClusterRoleKind
+	case *v1.Role:
+		return RoleKind
+	case *corev1.ServiceAccount:
+		return ServiceAccountKind
+	case *corev1.Namespace:
+		return NamespaceKind
+	case *corev1.Pod:
+		return PodKind
+	case *appsv1.Deployment:
+		return DeploymentKind
+	case *appsv1.StatefulSet:
+		return StatefulSetKind
+	case *appsv1.DaemonSet:
+		return DaemonSetKind
+	case *batchv1.Job:
+		return JobKind
+	case *batchv1.CronJob:
+		return CronJobKind
+	case *unstructured.Unstructured:
+		return openShiftKind(o)
+	}
+	return ""
+}
+
+func objectKey(namespace string, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
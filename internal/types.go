@@ -25,27 +25,28 @@ package internal
 import (
 	"github.com/rs/zerolog"
 	v1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 )
 
 type App struct {
-	KubeClient *kubernetes.Clientset
+	KubeClient kubernetes.Interface
 	Logger     *zerolog.Logger
+	// Store holds the objects to read instead of the cluster when set
+	Store *Store
+	// Cluster is the name of the cluster the app reads from
+	Cluster string
+	// Identities maps users to the groups of an external identity source
+	Identities *IdentityMap
 }
 
 type Generator interface {
 	GetBindings() (*Bindings, error)
+	GetRoles() (*Roles, error)
 }
 
 type WhatIfGenerator interface {
-	ProcessClusterRoleBinding(crb *v1.ClusterRoleBinding) struct {
-		Nodes []Node `json:"nodes"`
-		Links []Link `json:"links"`
-	}
-	ProcessRoleBinding(rb *v1.RoleBinding) struct {
-		Nodes []Node `json:"nodes"`
-		Links []Link `json:"links"`
-	}
+	WhatIf(objects []runtime.Object, operation string) (*WhatIfResult, error)
 }
 
 type Bindings struct {
@@ -53,11 +54,44 @@ type Bindings struct {
 	RoleBindings        *v1.RoleBindingList        `json:"roleBindings"`
 }
 
+type Roles struct {
+	ClusterRoles *v1.ClusterRoleList `json:"clusterRoles"`
+	Roles        *v1.RoleList        `json:"roles"`
+}
+
 type Data struct {
-	Id       int          `json:"id"`
-	Name     string       `json:"name"`
-	Kind     string       `json:"kind"`
-	Subjects []v1.Subject `json:"subjects"`
-	RoleRef  v1.RoleRef   `json:"roleRef"`
-	Raw      string       `json:"raw"`
+	Id        string       `json:"id"`
+	Cluster   string       `json:"cluster,omitempty"`
+	Name      string       `json:"name"`
+	Namespace string       `json:"namespace,omitempty"`
+	Kind      string       `json:"kind"`
+	Subjects  []v1.Subject `json:"subjects"`
+	RoleRef   v1.RoleRef   `json:"roleRef"`
+	// SubjectIds and RoleRefId are the node IDs of the subjects and the role of the binding
+	SubjectIds []string `json:"subjectIds"`
+	RoleRefId  string   `json:"roleRefId"`
+	// RoleRefComponents are the ClusterRoles aggregated into the role of the binding
+	RoleRefComponents []RoleComponent `json:"roleRefComponents,omitempty"`
+	Raw               string          `json:"raw"`
+	// Findings are the failed security checks of the binding, RiskScore sums their severities
+	Findings  []Finding `json:"findings,omitempty"`
+	RiskScore int       `json:"riskScore"`
+}
+
+// RoleData is a Role or ClusterRole with the rules it grants. The rules of aggregated ClusterRoles are
+// resolved from their components, which are listed with the rules each of them contributes.
+type RoleData struct {
+	Id              string              `json:"id"`
+	Cluster         string              `json:"cluster,omitempty"`
+	Name            string              `json:"name"`
+	Namespace       string              `json:"namespace,omitempty"`
+	Kind            string              `json:"kind"`
+	Labels          map[string]string   `json:"labels,omitempty"`
+	Annotations     map[string]string   `json:"annotations,omitempty"`
+	Rules           []v1.PolicyRule     `json:"rules"`
+	AggregationRule *v1.AggregationRule `json:"aggregationRule,omitempty"`
+	Components      []RoleComponent     `json:"components,omitempty"`
+	// AggregatedInto are the names of the ClusterRoles the ClusterRole is aggregated into
+	AggregatedInto []string `json:"aggregatedInto,omitempty"`
+	Raw            string   `json:"raw"`
 }
@@ -0,0 +1,240 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/rbac/v1"
+)
+
+// UsageReport attributes the requests of audit logs to the bindings and rules that authorized them.
+type UsageReport struct {
+	Cluster string `json:"cluster,omitempty"`
+	// Requests is the number of allowed requests read from the audit logs
+	Requests int `json:"requests"`
+	// Denied is the number of requests rejected by authentication or authorization
+	Denied int `json:"denied"`
+	// Unattributed is the number of allowed requests no grant explains, which were authorized by
+	// other authorizers, such as the Node authorizer, or by bindings that no longer exist
+	Unattributed int            `json:"unattributed"`
+	Grants       []GrantUsage   `json:"grants"`
+	Bindings     []BindingUsage `json:"bindings"`
+}
+
+// GrantUsage is the use of a rule granted by a binding.
+type GrantUsage struct {
+	Binding        BindingRef    `json:"binding"`
+	RoleRef        v1.RoleRef    `json:"roleRef"`
+	Rule           v1.PolicyRule `json:"rule"`
+	AggregatedFrom string        `json:"aggregatedFrom,omitempty"`
+	Subjects       []v1.Subject  `json:"subjects"`
+	// System is set for grants of bindings managed by Kubernetes
+	System   bool           `json:"system,omitempty"`
+	Requests int            `json:"requests"`
+	LastUsed *time.Time     `json:"lastUsed,omitempty"`
+	UsedBy   []SubjectUsage `json:"usedBy,omitempty"`
+}
+
+// SubjectUsage counts the requests of a subject.
+type SubjectUsage struct {
+	Subject  v1.Subject `json:"subject"`
+	Requests int        `json:"requests"`
+	LastUsed time.Time  `json:"lastUsed"`
+}
+
+// BindingUsage summarizes the use of the rules of a binding.
+type BindingUsage struct {
+	Id          string     `json:"id"`
+	Binding     BindingRef `json:"binding"`
+	System      bool       `json:"system,omitempty"`
+	Rules       int        `json:"rules"`
+	UnusedRules int        `json:"unusedRules"`
+	Requests    int        `json:"requests"`
+	LastUsed    *time.Time `json:"lastUsed,omitempty"`
+}
+
+// Unused reports whether the grant authorized none of the requests.
+func (u GrantUsage) Unused() bool {
+	return u.Requests == 0
+}
+
+// ComputeUsage attributes every allowed request of the audit events to the grant that authorized it.
+// The grant named by the authorization reason of the event is preferred, otherwise the first grant
+// allowing the request is taken, as the RBAC authorizer checks ClusterRoleBindings first.
+func ComputeUsage(bindings *Bindings, roles *Roles, events []AuditEvent, cluster string) UsageReport {
+	grants := ResolveGrants(bindings, roles)
+	system := systemBindings(bindings)
+
+	report := UsageReport{Cluster: cluster, Grants: make([]GrantUsage, len(grants))}
+	for i, grant := range grants {
+		report.Grants[i] = GrantUsage{
+			Binding:        grant.Binding,
+			RoleRef:        grant.RoleRef,
+			Rule:           grant.Rule,
+			AggregatedFrom: grant.AggregatedFrom,
+			Subjects:       grant.Subjects,
+			System:         system[grant.Binding],
+		}
+	}
+
+	// Requests repeat a lot, so the grant authorizing each distinct request is only looked up once
+	authorizing := map[string]int{}
+	for _, event := range events {
+		if event.Denied() {
+			report.Denied++
+			continue
+		}
+		report.Requests++
+
+		key := usageKey(event)
+		i, ok := authorizing[key]
+		if !ok {
+			i = authorizingGrant(grants, event)
+			authorizing[key] = i
+		}
+		if i < 0 {
+			report.Unattributed++
+			continue
+		}
+		report.Grants[i].record(event)
+	}
+
+	sort.SliceStable(report.Grants, func(i, j int) bool {
+		return bindingLess(report.Grants[i].Binding, report.Grants[j].Binding)
+	})
+	report.Bindings = summarizeBindings(report.Grants, cluster)
+
+	return report
+}
+
+// authorizingGrant returns the index of the grant that authorized the request of the event, or -1.
+func authorizingGrant(grants []Grant, event AuditEvent) int {
+	id := event.Identity()
+	request := event.Request()
+	reason := event.Annotations[auditReasonAnnotation]
+
+	match := -1
+	for i, grant := range grants {
+		if !grant.AppliesTo(id) || !grant.Allows(request) {
+			continue
+		}
+		if reason == "" || strings.Contains(reason, "by "+bindingReason(grant.Binding)) {
+			return i
+		}
+		if match < 0 {
+			match = i
+		}
+	}
+	return match
+}
+
+// bindingReason returns how the RBAC authorizer names a binding in the reason of its decisions.
+func bindingReason(ref BindingRef) string {
+	if ref.Namespace == "" {
+		return fmt.Sprintf("%s %q", ref.Kind, ref.Name)
+	}
+	return fmt.Sprintf("%s %q", ref.Kind, ref.Name+"/"+ref.Namespace)
+}
+
+func usageKey(event AuditEvent) string {
+	id := event.Identity()
+	attrs := event.Attributes()
+	return strings.Join([]string{
+		id.User, strings.Join(id.Groups, ","), attrs.Verb, attrs.APIGroup, attrs.Resource, attrs.Subresource,
+		attrs.Name, attrs.Namespace, event.Path(), event.Annotations[auditReasonAnnotation],
+	}, "\x00")
+}
+
+func (u *GrantUsage) record(event AuditEvent) {
+	at := event.RequestReceivedTimestamp
+	u.Requests++
+	if u.LastUsed == nil || at.After(*u.LastUsed) {
+		u.LastUsed = &at
+	}
+
+	subject := event.Subject()
+	for i := range u.UsedBy {
+		if u.UsedBy[i].Subject == subject {
+			u.UsedBy[i].Requests++
+			if at.After(u.UsedBy[i].LastUsed) {
+				u.UsedBy[i].LastUsed = at
+			}
+			return
+		}
+	}
+	u.UsedBy = append(u.UsedBy, SubjectUsage{Subject: subject, Requests: 1, LastUsed: at})
+}
+
+// summarizeBindings sums up the usage of the grants of every binding, the grants are sorted by binding.
+func summarizeBindings(grants []GrantUsage, cluster string) []BindingUsage {
+	summaries := []BindingUsage{}
+	for _, grant := range grants {
+		if n := len(summaries); n == 0 || summaries[n-1].Binding != grant.Binding {
+			summaries = append(summaries, BindingUsage{
+				Id:      NodeID(cluster, grant.Binding.Kind, grant.Binding.Namespace, grant.Binding.Name),
+				Binding: grant.Binding,
+				System:  grant.System,
+			})
+		}
+		summary := &summaries[len(summaries)-1]
+		summary.Rules++
+		summary.Requests += grant.Requests
+		if grant.Unused() {
+			summary.UnusedRules++
+		}
+		if grant.LastUsed != nil && (summary.LastUsed == nil || grant.LastUsed.After(*summary.LastUsed)) {
+			summary.LastUsed = grant.LastUsed
+		}
+	}
+	return summaries
+}
+
+// systemBindings returns the bindings managed by Kubernetes.
+func systemBindings(bindings *Bindings) map[BindingRef]bool {
+	system := map[BindingRef]bool{}
+	if bindings.ClusterRoleBindings != nil {
+		for _, crb := range bindings.ClusterRoleBindings.Items {
+			system[BindingRef{Kind: ClusterRoleBindingKind, Name: crb.Name}] = isSystemObject(crb.ObjectMeta)
+		}
+	}
+	if bindings.RoleBindings != nil {
+		for _, rb := range bindings.RoleBindings.Items {
+			system[BindingRef{Kind: RoleBindingKind, Name: rb.Name, Namespace: rb.Namespace}] = isSystemObject(rb.ObjectMeta)
+		}
+	}
+	return system
+}
+
+func bindingLess(a BindingRef, b BindingRef) bool {
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
@@ -23,19 +23,28 @@ THE SOFTWARE.
 package internal
 
 import (
-	"context"
+	"errors"
 	"fmt"
 
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/rbac/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// ErrUnsupportedObject is returned for what-if objects that are not bindings, roles or service accounts.
+var ErrUnsupportedObject = errors.New("unsupported resource type")
+
 type Node struct {
 	ID       string `json:"id"`
+	Cluster  string `json:"cluster,omitempty"`
 	Kind     string `json:"kind"`
 	ApiGroup string `json:"apiGroup"`
 	Label    string `json:"label"`
+	// Findings are the failed security checks of the object of the node
+	Findings []Finding `json:"findings,omitempty"`
+	// Role holds the rules of Role and ClusterRole nodes
+	Role *RoleData `json:"role,omitempty"`
 }
 
 type Link struct {
@@ -43,113 +52,334 @@ type Link struct {
 	Target string `json:"target"`
 }
 
-func (app App) ProcessClusterRoleBinding(crb *v1.ClusterRoleBinding) (data struct {
+const (
+	// WhatIfApply evaluates creating or replacing the submitted objects
+	WhatIfApply = "apply"
+	// WhatIfDelete evaluates deleting the submitted objects
+	WhatIfDelete = "delete"
+)
+
+// WhatIfResult is the graph of a submitted change and the delta it causes against the current state:
+// the bindings and roles it adds, replaces or removes, and the subjects that gain or lose permissions.
+type WhatIfResult struct {
 	Nodes []Node `json:"nodes"`
 	Links []Link `json:"links"`
-}) {
-	crbNodeID := crb.Kind + "-" + crb.Name
-	data.Nodes = append(data.Nodes, Node{
-		ID:       crbNodeID,
-		Kind:     crb.Kind,
-		ApiGroup: crb.APIVersion,
-		Label:    crbNodeID,
-	})
+	Diff
+}
 
-	for _, subject := range crb.Subjects {
-		subjectInfo := fetchSubjectDetails(app.KubeClient, subject)
-		if subjectInfo != nil {
-			data.Nodes = append(data.Nodes, *subjectInfo)
-			data.Links = append(data.Links, Link{
-				Source: crbNodeID,
-				Target: subjectInfo.ID,
-			})
+// WhatIf applies or deletes the objects on an in-memory overlay of the current state, without
+// changing it, and returns the combined graph of the objects and the delta of the change. Objects
+// that replace an existing object of the same name are compared to it. Namespaced objects without
+// a namespace default to the default namespace.
+func (app App) WhatIf(objects []runtime.Object, operation string) (*WhatIfResult, error) {
+	if operation != WhatIfApply && operation != WhatIfDelete {
+		return nil, fmt.Errorf("unknown what-if operation %q", operation)
+	}
+
+	submitted := make([]runtime.Object, 0, len(objects))
+	for _, obj := range objects {
+		obj, err := whatIfObject(obj)
+		if err != nil {
+			return nil, err
 		}
+		submitted = append(submitted, obj)
 	}
 
-	roleRefInfo := fetchRoleRefDetails(app.KubeClient, crb.RoleRef)
-	if roleRefInfo != nil {
-		data.Nodes = append(data.Nodes, *roleRefInfo)
-		data.Links = append(data.Links, Link{
-			Source: crbNodeID,
-			Target: roleRefInfo.ID,
-		})
+	overlay, err := app.overlay()
+	if err != nil {
+		return nil, err
 	}
+	oldBindings, oldRoles := overlay.Bindings(), overlay.Roles()
 
-	return data
-}
+	result := &WhatIfResult{Nodes: []Node{}, Links: []Link{}}
 
-func (app App) ProcessRoleBinding(rb *v1.RoleBinding) (data struct {
-	Nodes []Node `json:"nodes"`
-	Links []Link `json:"links"`
-}) {
-	rbNodeID := rb.Kind + "-" + rb.Name
-	data.Nodes = append(data.Nodes, Node{
-		ID:       rbNodeID,
-		Kind:     rb.Kind,
-		ApiGroup: rb.APIVersion,
-		Label:    rbNodeID,
-	})
+	if operation == WhatIfDelete {
+		// Deleted objects are shown as they currently are, with the bindings they take part in
+		for i, obj := range submitted {
+			if stored, ok := storedObject(overlay, obj); ok {
+				submitted[i] = stored
+			}
+			result.addObject(app.Cluster, overlay, oldBindings, submitted[i])
+		}
+		for _, obj := range submitted {
+			overlay.Delete(obj)
+		}
+	} else {
+		for _, obj := range submitted {
+			overlay.Add(obj)
+		}
+		newBindings := overlay.Bindings()
+		for _, obj := range submitted {
+			result.addObject(app.Cluster, overlay, newBindings, obj)
+		}
+	}
 
-	for _, subject := range rb.Subjects {
-		subjectInfo := fetchSubjectDetails(app.KubeClient, subject)
-		if subjectInfo != nil {
-			data.Nodes = append(data.Nodes, *subjectInfo)
-			data.Links = append(data.Links, Link{
-				Source: rbNodeID,
-				Target: subjectInfo.ID,
-			})
+	newBindings, newRoles := overlay.Bindings(), overlay.Roles()
+	result.Diff = *diffStates(oldBindings, oldRoles, newBindings, newRoles)
+
+	findings := FindFindings(newBindings, newRoles, app.Cluster, false)
+	for i := range result.Nodes {
+		for _, f := range findings {
+			if f.NodeID == result.Nodes[i].ID {
+				result.Nodes[i].Findings = append(result.Nodes[i].Findings, f)
+			}
+		}
+	}
+
+	// Roles are shown as they are after the change, or as they were when it deletes them
+	roles := map[string]RoleData{}
+	for _, state := range []*Roles{oldRoles, newRoles} {
+		for _, role := range GenerateRoleData(state, app.Cluster) {
+			roles[role.Id] = role
+		}
+	}
+	for i := range result.Nodes {
+		if role, ok := roles[result.Nodes[i].ID]; ok {
+			result.Nodes[i].Role = &role
 		}
 	}
 
-	roleRefInfo := fetchRoleRefDetails(app.KubeClient, rb.RoleRef)
-	if roleRefInfo != nil {
-		data.Nodes = append(data.Nodes, *roleRefInfo)
-		data.Links = append(data.Links, Link{
-			Source: rbNodeID,
-			Target: roleRefInfo.ID,
+	return result, nil
+}
+
+// addObject adds a submitted object to the graph, with the bindings it takes part in.
+func (result *WhatIfResult) addObject(cluster string, store *Store, bindings *Bindings, obj runtime.Object) {
+	switch o := obj.(type) {
+	case *v1.ClusterRoleBinding:
+		result.addBinding(cluster, store, ClusterRoleBindingKind, "", o.Name, o.Subjects, o.RoleRef)
+	case *v1.RoleBinding:
+		result.addBinding(cluster, store, RoleBindingKind, o.Namespace, o.Name, o.Subjects, o.RoleRef)
+	case *v1.ClusterRole:
+		roleRef := v1.RoleRef{APIGroup: v1.GroupName, Kind: ClusterRoleKind, Name: o.Name}
+		result.addNode(Node{
+			ID:       RoleRefID(cluster, roleRef, ""),
+			Cluster:  cluster,
+			Kind:     ClusterRoleKind,
+			ApiGroup: v1.GroupName,
+			Label:    nodeLabel(ClusterRoleKind, "", o.Name),
 		})
+		// Bindings of the ClusterRoles it is aggregated into are affected too
+		names := append([]string{o.Name}, aggregatesOf(o.Name, aggregationIndex(store.Roles().ClusterRoles.Items))...)
+		for _, crb := range bindings.ClusterRoleBindings.Items {
+			if crb.RoleRef.Kind == ClusterRoleKind && contains(names, crb.RoleRef.Name) {
+				result.addBinding(cluster, store, ClusterRoleBindingKind, "", crb.Name, crb.Subjects, crb.RoleRef)
+			}
+		}
+		for _, rb := range bindings.RoleBindings.Items {
+			if rb.RoleRef.Kind == ClusterRoleKind && contains(names, rb.RoleRef.Name) {
+				result.addBinding(cluster, store, RoleBindingKind, rb.Namespace, rb.Name, rb.Subjects, rb.RoleRef)
+			}
+		}
+	case *v1.Role:
+		roleRef := v1.RoleRef{APIGroup: v1.GroupName, Kind: RoleKind, Name: o.Name}
+		result.addNode(Node{
+			ID:       RoleRefID(cluster, roleRef, o.Namespace),
+			Cluster:  cluster,
+			Kind:     RoleKind,
+			ApiGroup: v1.GroupName,
+			Label:    nodeLabel(RoleKind, o.Namespace, o.Name),
+		})
+		for _, rb := range bindings.RoleBindings.Items {
+			if rb.Namespace == o.Namespace && rb.RoleRef.Kind == RoleKind && rb.RoleRef.Name == o.Name {
+				result.addBinding(cluster, store, RoleBindingKind, rb.Namespace, rb.Name, rb.Subjects, rb.RoleRef)
+			}
+		}
+	case *corev1.ServiceAccount:
+		subject := v1.Subject{Kind: v1.ServiceAccountKind, Namespace: o.Namespace, Name: o.Name}
+		result.addNode(Node{
+			ID:       SubjectID(cluster, subject, o.Namespace),
+			Cluster:  cluster,
+			Kind:     v1.ServiceAccountKind,
+			ApiGroup: "",
+			Label:    nodeLabel(v1.ServiceAccountKind, o.Namespace, o.Name),
+		})
+		id := IdentityFor(subject)
+		for _, crb := range bindings.ClusterRoleBindings.Items {
+			if bindsIdentity(id, crb.Subjects, "") {
+				result.addBinding(cluster, store, ClusterRoleBindingKind, "", crb.Name, crb.Subjects, crb.RoleRef)
+			}
+		}
+		for _, rb := range bindings.RoleBindings.Items {
+			if bindsIdentity(id, rb.Subjects, rb.Namespace) {
+				result.addBinding(cluster, store, RoleBindingKind, rb.Namespace, rb.Name, rb.Subjects, rb.RoleRef)
+			}
+		}
 	}
+}
 
-	return data
+// storedObject returns the object of the store with the kind, namespace and name of obj.
+func storedObject(store *Store, obj runtime.Object) (runtime.Object, bool) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, false
+	}
+	return store.Get(storeKind(obj), accessor.GetNamespace(), accessor.GetName())
 }
 
-func fetchSubjectDetails(client *kubernetes.Clientset, subject v1.Subject) *Node {
-	if subject.Kind == "ServiceAccount" {
-		_, err := client.CoreV1().ServiceAccounts(subject.Namespace).Get(context.TODO(), subject.Name, metav1.GetOptions{})
+// whatIfObject validates an object submitted to a what-if and defaults its namespace.
+func whatIfObject(obj runtime.Object) (runtime.Object, error) {
+	switch obj.(type) {
+	case *v1.ClusterRoleBinding, *v1.ClusterRole:
+		return obj, nil
+	case *v1.RoleBinding, *v1.Role, *corev1.ServiceAccount:
+		obj = obj.DeepCopyObject()
+		accessor, err := meta.Accessor(obj)
 		if err != nil {
-			return nil
+			return nil, err
 		}
+		if accessor.GetNamespace() == "" {
+			accessor.SetNamespace(corev1.NamespaceDefault)
+		}
+		return obj, nil
 	}
-	return &Node{
-		ID:       subject.Kind + "-" + subject.Name,
-		Kind:     subject.Kind,
-		ApiGroup: subject.APIGroup,
-		Label:    subject.Kind + "-" + subject.Name,
+
+	return nil, fmt.Errorf("%w %s", ErrUnsupportedObject, obj.GetObjectKind().GroupVersionKind().Kind)
+}
+
+// overlay returns a copy of the bindings, roles and service accounts of the app in a new store.
+func (app App) overlay() (*Store, error) {
+	bindings, err := app.GetBindings()
+	if err != nil {
+		return nil, err
+	}
+	roles, err := app.GetRoles()
+	if err != nil {
+		return nil, err
+	}
+	serviceAccounts, err := app.GetServiceAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	store := NewStore()
+	for i := range bindings.ClusterRoleBindings.Items {
+		store.Add(&bindings.ClusterRoleBindings.Items[i])
+	}
+	for i := range bindings.RoleBindings.Items {
+		store.Add(&bindings.RoleBindings.Items[i])
+	}
+	for i := range roles.ClusterRoles.Items {
+		store.Add(&roles.ClusterRoles.Items[i])
+	}
+	for i := range roles.Roles.Items {
+		store.Add(&roles.Roles.Items[i])
+	}
+	for i := range serviceAccounts.Items {
+		store.Add(&serviceAccounts.Items[i])
 	}
+
+	return store, nil
 }
 
-func fetchRoleRefDetails(client *kubernetes.Clientset, roleRef v1.RoleRef) *Node {
-	switch roleRef.Kind {
-	case "ClusterRole":
-		_, err := client.RbacV1().ClusterRoles().Get(context.TODO(), roleRef.Name, metav1.GetOptions{})
-		if err != nil {
-			fmt.Printf("Error fetching cluster role: %v\n", err)
-			return nil
+func bindsIdentity(id Identity, subjects []v1.Subject, namespace string) bool {
+	for _, subject := range subjects {
+		if id.Matches(subject, namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// addBinding adds a binding, its subjects and its role to the graph. Service accounts and
+// ClusterRoles that do not exist in the store are left out.
+func (result *WhatIfResult) addBinding(cluster string, store *Store, kind string, namespace string, name string, subjects []v1.Subject, roleRef v1.RoleRef) {
+	bindingID := NodeID(cluster, kind, namespace, name)
+	result.addNode(Node{
+		ID:       bindingID,
+		Cluster:  cluster,
+		Kind:     kind,
+		ApiGroup: v1.SchemeGroupVersion.String(),
+		Label:    nodeLabel(kind, namespace, name),
+	})
+
+	for _, subject := range subjects {
+		subjectNamespace := ""
+		if subject.Kind == v1.ServiceAccountKind {
+			subjectNamespace = subject.Namespace
+			if subjectNamespace == "" {
+				subjectNamespace = namespace
+			}
+			if _, ok := store.Get(ServiceAccountKind, subjectNamespace, subject.Name); !ok {
+				continue
+			}
+		}
+		subjectID := SubjectID(cluster, subject, namespace)
+		result.addNode(Node{
+			ID:       subjectID,
+			Cluster:  cluster,
+			Kind:     subject.Kind,
+			ApiGroup: subject.APIGroup,
+			Label:    nodeLabel(subject.Kind, subjectNamespace, subject.Name),
+		})
+		result.addLink(Link{Source: bindingID, Target: subjectID})
+	}
+
+	roleNamespace := ""
+	if roleRef.Kind == RoleKind {
+		roleNamespace = namespace
+	} else if _, ok := store.Get(ClusterRoleKind, "", roleRef.Name); !ok {
+		return
+	}
+	roleID := RoleRefID(cluster, roleRef, namespace)
+	result.addNode(Node{
+		ID:       roleID,
+		Cluster:  cluster,
+		Kind:     roleRef.Kind,
+		ApiGroup: roleRef.APIGroup,
+		Label:    nodeLabel(roleRef.Kind, roleNamespace, roleRef.Name),
+	})
+	result.addLink(Link{Source: bindingID, Target: roleID})
+
+	if roleRef.Kind == ClusterRoleKind {
+		result.addComponents(cluster, store.Roles().ClusterRoles.Items, roleRef.Name)
+	}
+}
+
+// addComponents adds the ClusterRoles aggregated into a ClusterRole to the graph, linked from it.
+func (result *WhatIfResult) addComponents(cluster string, clusterRoles []v1.ClusterRole, name string) {
+	for _, cr := range clusterRoles {
+		if cr.Name != name {
+			continue
 		}
-		return &Node{
-			ID:       roleRef.Kind + "-" + roleRef.Name,
-			Kind:     roleRef.Kind,
-			ApiGroup: roleRef.APIGroup,
-			Label:    roleRef.Kind + "-" + roleRef.Name,
+		for _, component := range AggregationComponents(cr, clusterRoles) {
+			componentID := NodeID(cluster, ClusterRoleKind, "", component)
+			link := Link{Source: NodeID(cluster, ClusterRoleKind, "", name), Target: componentID}
+			if result.hasLink(link) {
+				continue
+			}
+			result.addNode(Node{
+				ID:       componentID,
+				Cluster:  cluster,
+				Kind:     ClusterRoleKind,
+				ApiGroup: v1.GroupName,
+				Label:    nodeLabel(ClusterRoleKind, "", component),
+			})
+			result.addLink(link)
+			result.addComponents(cluster, clusterRoles, component)
 		}
-	case "Role":
-		return &Node{
-			ID:       roleRef.Kind + "-" + roleRef.Name,
-			Kind:     roleRef.Kind,
-			ApiGroup: roleRef.APIGroup,
-			Label:    roleRef.Kind + "-" + roleRef.Name,
+	}
+}
+
+func (result *WhatIfResult) addNode(node Node) {
+	for _, existing := range result.Nodes {
+		if existing.ID == node.ID {
+			return
 		}
 	}
+	result.Nodes = append(result.Nodes, node)
+}
 
-	return nil
+func (result *WhatIfResult) addLink(link Link) {
+	if !result.hasLink(link) {
+		result.Links = append(result.Links, link)
+	}
+}
+
+func (result *WhatIfResult) hasLink(link Link) bool {
+	for _, existing := range result.Links {
+		if existing == link {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,184 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/rbac/v1"
+)
+
+// ResourceAttributes describes a request on a resource. An APIGroup of "*" matches
+// rules of any API group, and AllNamespaces matches grants of any namespace.
+type ResourceAttributes struct {
+	Verb          string `json:"verb"`
+	APIGroup      string `json:"apiGroup"`
+	Resource      string `json:"resource"`
+	Subresource   string `json:"subresource,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Namespace     string `json:"namespace,omitempty"`
+	AllNamespaces bool   `json:"allNamespaces,omitempty"`
+}
+
+type WhoCanResult struct {
+	Cluster string        `json:"cluster,omitempty"`
+	Subject v1.Subject    `json:"subject"`
+	Binding BindingRef    `json:"binding"`
+	RoleRef v1.RoleRef    `json:"roleRef"`
+	Rule    v1.PolicyRule `json:"rule"`
+	// AggregatedFrom is the ClusterRole the rule is aggregated from when the role is an aggregated ClusterRole
+	AggregatedFrom string `json:"aggregatedFrom,omitempty"`
+	// Members are the users of a Group subject in identity sources
+	Members []string `json:"members,omitempty"`
+}
+
+// ParseResource splits a resource in the kubectl "resource[.group][/subresource]" form.
+// The API group is "*" when it is not given, so that rules of any group match.
+func ParseResource(resource string) (name string, group string, subresource string) {
+	resource, subresource, _ = strings.Cut(resource, "/")
+	name, group, found := strings.Cut(resource, ".")
+	if !found {
+		group = v1.APIGroupAll
+	}
+	return name, group, subresource
+}
+
+// WhoCan returns every subject that is granted the request, together with the binding and rule granting it.
+func WhoCan(bindings *Bindings, roles *Roles, attrs ResourceAttributes) []WhoCanResult {
+	results := []WhoCanResult{}
+
+	for _, grant := range ResolveGrants(bindings, roles) {
+		if !grant.Matches(attrs) {
+			continue
+		}
+		for _, subject := range grant.Subjects {
+			if subject.Kind == v1.ServiceAccountKind && subject.Namespace == "" {
+				subject.Namespace = grant.Binding.Namespace
+			}
+			results = append(results, WhoCanResult{
+				Subject:        subject,
+				Binding:        grant.Binding,
+				RoleRef:        grant.RoleRef,
+				Rule:           grant.Rule,
+				AggregatedFrom: grant.AggregatedFrom,
+			})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i].Subject, results[j].Subject
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	return results
+}
+
+// Matches reports whether the grant allows the request.
+func (g Grant) Matches(attrs ResourceAttributes) bool {
+	// Grants of RoleBindings only apply to requests in their own namespace
+	if g.Namespace != "" && !attrs.AllNamespaces && g.Namespace != attrs.Namespace {
+		return false
+	}
+
+	return RuleAllows(g.Rule, attrs)
+}
+
+// RuleAllows reports whether a policy rule allows the request, following the
+// matching semantics of the Kubernetes RBAC authorizer.
+func RuleAllows(rule v1.PolicyRule, attrs ResourceAttributes) bool {
+	return verbMatches(rule, attrs.Verb) &&
+		apiGroupMatches(rule, attrs.APIGroup) &&
+		resourceMatches(rule, attrs.Resource, attrs.Subresource) &&
+		resourceNameMatches(rule, attrs.Name)
+}
+
+// NonResourceRuleAllows reports whether a policy rule allows a request on a non-resource URL.
+// A trailing "*" in the URLs of the rule matches any path with the preceding prefix.
+func NonResourceRuleAllows(rule v1.PolicyRule, verb string, path string) bool {
+	if !verbMatches(rule, verb) {
+		return false
+	}
+	for _, url := range rule.NonResourceURLs {
+		if url == v1.NonResourceAll || url == path {
+			return true
+		}
+		if strings.HasSuffix(url, "*") && strings.HasPrefix(path, strings.TrimRight(url, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func verbMatches(rule v1.PolicyRule, verb string) bool {
+	for _, v := range rule.Verbs {
+		if v == v1.VerbAll || v == verb {
+			return true
+		}
+	}
+	return false
+}
+
+func apiGroupMatches(rule v1.PolicyRule, group string) bool {
+	for _, g := range rule.APIGroups {
+		if g == v1.APIGroupAll || g == group || group == v1.APIGroupAll {
+			return true
+		}
+	}
+	return false
+}
+
+func resourceMatches(rule v1.PolicyRule, resource string, subresource string) bool {
+	combined := resource
+	if subresource != "" {
+		combined = resource + "/" + subresource
+	}
+
+	for _, r := range rule.Resources {
+		switch {
+		case r == v1.ResourceAll, r == combined:
+			return true
+		case subresource != "" && r == v1.ResourceAll+"/"+subresource:
+			return true
+		}
+	}
+	return false
+}
+
+func resourceNameMatches(rule v1.PolicyRule, name string) bool {
+	if len(rule.ResourceNames) == 0 {
+		return true
+	}
+	for _, n := range rule.ResourceNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,335 @@
+/*
+Copyright © 2024 Furkan Pehlivan <furkanpehlivan34@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	PodKind         = "Pod"
+	DeploymentKind  = "Deployment"
+	StatefulSetKind = "StatefulSet"
+	DaemonSetKind   = "DaemonSet"
+	JobKind         = "Job"
+	CronJobKind     = "CronJob"
+)
+
+// workloadKinds are the kinds of the objects that run pods with a service account.
+var workloadKinds = []string{PodKind, DeploymentKind, StatefulSetKind, DaemonSetKind, JobKind, CronJobKind}
+
+// Workload is a workload and the service account its pods run as.
+type Workload struct {
+	Id               string `json:"id"`
+	Cluster          string `json:"cluster,omitempty"`
+	Kind             string `json:"kind"`
+	Name             string `json:"name"`
+	Namespace        string `json:"namespace"`
+	ServiceAccount   string `json:"serviceAccount"`
+	ServiceAccountId string `json:"serviceAccountId"`
+	// AutomountToken reports whether the token of the service account is mounted into the pods, as set
+	// by the pod template or else by the service account
+	AutomountToken bool `json:"automountToken"`
+	// ClusterAdmin reports whether the service account is a cluster admin or has an escalation path to
+	// cluster admin, which is then set as Escalation
+	ClusterAdmin bool        `json:"clusterAdmin"`
+	Escalation   *Escalation `json:"escalation,omitempty"`
+}
+
+// GetWorkloads returns the Pods, Deployments, StatefulSets, DaemonSets, Jobs and CronJobs of the cluster.
+// Workloads are not needed to read RBAC, so the kinds that cannot be listed, for example because
+// rbac-wizard is not allowed to, are left out and reported by the error along with the other workloads.
+func (app App) GetWorkloads() ([]runtime.Object, error) {
+	var objects []runtime.Object
+	if app.Store != nil {
+		for _, kind := range workloadKinds {
+			objects = append(objects, app.Store.List(kind)...)
+		}
+		return objects, app.Store.WorkloadsError()
+	}
+
+	clientset := app.KubeClient
+	ctx := context.TODO()
+
+	var errs []error
+
+	pods, err := clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to list pods: %w", err))
+	} else {
+		for i := range pods.Items {
+			objects = append(objects, &pods.Items[i])
+		}
+	}
+
+	deployments, err := clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to list deployments: %w", err))
+	} else {
+		for i := range deployments.Items {
+			objects = append(objects, &deployments.Items[i])
+		}
+	}
+
+	statefulSets, err := clientset.AppsV1().StatefulSets("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to list statefulsets: %w", err))
+	} else {
+		for i := range statefulSets.Items {
+			objects = append(objects, &statefulSets.Items[i])
+		}
+	}
+
+	daemonSets, err := clientset.AppsV1().DaemonSets("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to list daemonsets: %w", err))
+	} else {
+		for i := range daemonSets.Items {
+			objects = append(objects, &daemonSets.Items[i])
+		}
+	}
+
+	jobs, err := clientset.BatchV1().Jobs("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to list jobs: %w", err))
+	} else {
+		for i := range jobs.Items {
+			objects = append(objects, &jobs.Items[i])
+		}
+	}
+
+	cronJobs, err := clientset.BatchV1().CronJobs("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to list cronjobs: %w", err))
+	} else {
+		for i := range cronJobs.Items {
+			objects = append(objects, &cronJobs.Items[i])
+		}
+	}
+
+	return objects, workloadsError(errs)
+}
+
+// workloadsError returns the errors of the workload kinds that could not be listed, if any.
+func workloadsError(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("workloads unavailable: %w", errors.Join(errs...))
+}
+
+// slimWorkload returns a copy of a workload with only what rbac-wizard uses: its name, namespace, controller,
+// pod template hash and the service account settings of its pods. The rest of the specs, such as environment
+// variables, is dropped so that it is neither kept in memory nor written to snapshots.
+func slimWorkload(obj runtime.Object) runtime.Object {
+	kind, meta, spec, ok := workloadPodSpec(obj)
+	if !ok {
+		return obj
+	}
+
+	objectMeta := metav1.ObjectMeta{
+		Name:            meta.GetName(),
+		Namespace:       meta.GetNamespace(),
+		OwnerReferences: meta.GetOwnerReferences(),
+	}
+	if hash, ok := meta.GetLabels()[appsv1.DefaultDeploymentUniqueLabelKey]; ok {
+		objectMeta.Labels = map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: hash}
+	}
+	podSpec := corev1.PodSpec{
+		ServiceAccountName:           spec.ServiceAccountName,
+		DeprecatedServiceAccount:     spec.DeprecatedServiceAccount,
+		AutomountServiceAccountToken: spec.AutomountServiceAccountToken,
+	}
+	template := corev1.PodTemplateSpec{Spec: podSpec}
+
+	switch kind {
+	case PodKind:
+		return &corev1.Pod{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: kind}, ObjectMeta: objectMeta, Spec: podSpec}
+	case DeploymentKind:
+		return &appsv1.Deployment{TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: kind}, ObjectMeta: objectMeta,
+			Spec: appsv1.DeploymentSpec{Template: template}}
+	case StatefulSetKind:
+		return &appsv1.StatefulSet{TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: kind}, ObjectMeta: objectMeta,
+			Spec: appsv1.StatefulSetSpec{Template: template}}
+	case DaemonSetKind:
+		return &appsv1.DaemonSet{TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: kind}, ObjectMeta: objectMeta,
+			Spec: appsv1.DaemonSetSpec{Template: template}}
+	case JobKind:
+		return &batchv1.Job{TypeMeta: metav1.TypeMeta{APIVersion: "batch/v1", Kind: kind}, ObjectMeta: objectMeta,
+			Spec: batchv1.JobSpec{Template: template}}
+	default:
+		return &batchv1.CronJob{TypeMeta: metav1.TypeMeta{APIVersion: "batch/v1", Kind: kind}, ObjectMeta: objectMeta,
+			Spec: batchv1.CronJobSpec{JobTemplate: batchv1.JobTemplateSpec{Spec: batchv1.JobSpec{Template: template}}}}
+	}
+}
+
+// ResolveWorkloads returns the workloads of the objects with the service accounts they run as and whether those
+// are cluster admins. Pods and Jobs created by one of the other workloads are left out, since their owner
+// represents them, while those of other controllers such as operators are kept.
+func ResolveWorkloads(objects []runtime.Object, serviceAccounts *corev1.ServiceAccountList, bindings *Bindings, roles *Roles, cluster string) []Workload {
+	automount := map[string]*bool{}
+	if serviceAccounts != nil {
+		for _, sa := range serviceAccounts.Items {
+			automount[objectKey(sa.Namespace, sa.Name)] = sa.AutomountServiceAccountToken
+		}
+	}
+
+	grants := ResolveGrants(bindings, roles)
+	admins := map[string]bool{}
+	escalations := map[string]Escalation{}
+	for _, e := range FindEscalations(bindings, roles, true) {
+		if e.Technique == TechniqueClusterAdmin && e.Subject.Kind == v1.ServiceAccountKind {
+			if _, ok := escalations[subjectKey(e.Subject)]; !ok {
+				escalations[subjectKey(e.Subject)] = e
+			}
+		}
+	}
+
+	present := map[string]bool{}
+	for _, obj := range objects {
+		if kind, meta, _, ok := workloadPodSpec(obj); ok {
+			present[kind+"/"+objectKey(meta.GetNamespace(), meta.GetName())] = true
+		}
+	}
+
+	workloads := []Workload{}
+	for _, obj := range objects {
+		kind, meta, spec, ok := workloadPodSpec(obj)
+		if !ok || representedByController(meta, present) {
+			continue
+		}
+
+		name := spec.ServiceAccountName
+		if name == "" {
+			name = spec.DeprecatedServiceAccount
+		}
+		if name == "" {
+			name = "default"
+		}
+		subject := v1.Subject{Kind: v1.ServiceAccountKind, Namespace: meta.GetNamespace(), Name: name}
+
+		key := subjectKey(subject)
+		if _, ok := admins[key]; !ok {
+			admins[key] = clusterAdmin(grants, subject)
+		}
+
+		mounted := spec.AutomountServiceAccountToken
+		if mounted == nil {
+			mounted = automount[objectKey(subject.Namespace, subject.Name)]
+		}
+
+		workload := Workload{
+			Id:               NodeID(cluster, kind, meta.GetNamespace(), meta.GetName()),
+			Cluster:          cluster,
+			Kind:             kind,
+			Name:             meta.GetName(),
+			Namespace:        meta.GetNamespace(),
+			ServiceAccount:   name,
+			ServiceAccountId: SubjectID(cluster, subject, subject.Namespace),
+			AutomountToken:   mounted == nil || *mounted,
+			ClusterAdmin:     admins[key],
+		}
+		if e, ok := escalations[key]; ok && !workload.ClusterAdmin {
+			e.Cluster = cluster
+			workload.ClusterAdmin, workload.Escalation = true, &e
+		}
+		workloads = append(workloads, workload)
+	}
+
+	sort.SliceStable(workloads, func(i, j int) bool {
+		if workloads[i].Namespace != workloads[j].Namespace {
+			return workloads[i].Namespace < workloads[j].Namespace
+		}
+		if workloads[i].Kind != workloads[j].Kind {
+			return workloads[i].Kind < workloads[j].Kind
+		}
+		return workloads[i].Name < workloads[j].Name
+	})
+	return workloads
+}
+
+// clusterAdmin reports whether the grants give the subject every verb on every resource cluster wide,
+// through its own bindings or the bindings of its groups.
+func clusterAdmin(grants []Grant, subject v1.Subject) bool {
+	id := IdentityFor(subject)
+	for _, grant := range grants {
+		if grant.Namespace == "" && isAdminRule(grant.Rule) && grant.AppliesTo(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// representedByController reports whether the controller of a workload is one of the present workloads, given
+// by kind, namespace and name. Pods of Deployments are controlled by a ReplicaSet, which is named after the
+// Deployment and the pod template hash, and are represented by the Deployment.
+func representedByController(meta metav1.Object, present map[string]bool) bool {
+	controller := metav1.GetControllerOf(meta)
+	if controller == nil {
+		return false
+	}
+
+	kind, name := controller.Kind, controller.Name
+	if kind == "ReplicaSet" {
+		hash, ok := meta.GetLabels()[appsv1.DefaultDeploymentUniqueLabelKey]
+		if !ok {
+			return false
+		}
+		if name, ok = strings.CutSuffix(name, "-"+hash); !ok {
+			return false
+		}
+		kind = DeploymentKind
+	}
+
+	return present[kind+"/"+objectKey(meta.GetNamespace(), name)]
+}
+
+// workloadPodSpec returns the kind, the metadata and the pod spec of a workload.
+func workloadPodSpec(obj runtime.Object) (string, metav1.Object, corev1.PodSpec, bool) {
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		return PodKind, o, o.Spec, true
+	case *appsv1.Deployment:
+		return DeploymentKind, o, o.Spec.Template.Spec, true
+	case *appsv1.StatefulSet:
+		return StatefulSetKind, o, o.Spec.Template.Spec, true
+	case *appsv1.DaemonSet:
+		return DaemonSetKind, o, o.Spec.Template.Spec, true
+	case *batchv1.Job:
+		return JobKind, o, o.Spec.Template.Spec, true
+	case *batchv1.CronJob:
+		return CronJobKind, o, o.Spec.JobTemplate.Spec.Template.Spec, true
+	}
+	return "", nil, corev1.PodSpec{}, false
+}
@@ -1,11 +1,12 @@
 "use client";
 import Editor from '@monaco-editor/react';
 import { Card, CardBody, CardHeader } from "@nextui-org/card";
-import { Badge, Button } from "@nextui-org/react";
+import { Badge, Button, Input, Select, SelectItem } from "@nextui-org/react";
 import { useTheme } from 'next-themes';
 import { useState } from 'react';
 import axios from 'axios';
 import DisjointGraph from '@/components/graph';
+import PermissionChanges, { ObjectChange, ObjectChanges, PermissionChange } from '@/components/permission-changes';
 import { IoInformationCircle } from "react-icons/io5";
 import {Tooltip} from "@nextui-org/tooltip";
 
@@ -15,15 +16,38 @@ export default function WhatIfPage() {
     const editorTheme = isDarkMode ? 'vs-dark' : 'light';
 
     const [yamlContent, setYamlContent] = useState('');
-    const [graphData, setGraphData] = useState<{ nodes: any[]; links: any[] } | null>(null);
+    const [graphData, setGraphData] = useState<{
+        nodes: any[];
+        links: any[];
+        bindings?: ObjectChange[];
+        roles?: ObjectChange[];
+        permissions?: PermissionChange[];
+    } | null>(null);
+
+    // The least privilege form generates the roles of a subject from the audit logs of the server into the editor
+    const [subjectKind, setSubjectKind] = useState('ServiceAccount');
+    const [subjectNamespace, setSubjectNamespace] = useState('');
+    const [subjectName, setSubjectName] = useState('');
+    const [leastPrivilegeError, setLeastPrivilegeError] = useState('');
+
+    const handleLeastPrivilegeClick = async () => {
+        try {
+            const namespace = (subjectKind === 'ServiceAccount' && subjectNamespace) || '-';
+            const response = await axios.get(`/api/subjects/${subjectKind}/${encodeURIComponent(namespace)}/${encodeURIComponent(subjectName)}/least-privilege`);
+            setYamlContent(response.data.yaml);
+            setLeastPrivilegeError(response.data.requests === 0 ? 'No requests of the subject found in the audit logs' : '');
+        } catch (error: any) {
+            setLeastPrivilegeError(error.response?.data ?? 'Failed to generate roles');
+        }
+    };
 
     const handleEditorChange = (value: string | undefined) => {
         setYamlContent(value || '');
     };
 
-    const handleGenerateClick = async () => {
+    const handleGenerateClick = async (operation: 'apply' | 'delete') => {
         try {
-            const response = await axios.post('/api/what-if', { yaml: yamlContent });
+            const response = await axios.post('/api/what-if', { yaml: yamlContent, operation });
             setGraphData(response.data);
         } catch (error) {
             console.error('Error generating graph:', error);
@@ -43,7 +67,7 @@ export default function WhatIfPage() {
                                     content={
                                         <div className="px-1 py-2">
                                             <div className="text-large font-bold">What is `What If?`</div>
-                                            <div className="text-small">`What If?` helps you easily add one of your ClusterRoleBinding or RoleBinding manifests. When you click the `Generate` button, it visualizes your binding in a map format.</div>
+                                            <div className="text-small">`What If?` helps you easily evaluate a change set of ClusterRoleBinding, RoleBinding, ClusterRole, Role and ServiceAccount manifests, separated by `---` or wrapped in a `List`. When you click the `Generate` button, it applies them on top of the current cluster state, visualizes them in a map format and shows the bindings and roles they add, replace or remove and which subjects gain or lose which permissions. The `Simulate delete` button shows who would lose access if they were deleted. When the server was given audit logs, `Least privilege` fills the editor with the minimal roles of a subject generated from its requests.</div>
                                             <br />
                                             <div className="text-tiny">⚠️Please note that this feature is still in beta. If you encounter any issues, please report them on our GitHub page.</div>
                                         </div>
@@ -55,11 +79,23 @@ export default function WhatIfPage() {
                                 </Tooltip>
                         </CardHeader>
                     </Badge>
+                    <div style={{ display: 'flex', gap: '10px', alignItems: 'center', padding: '0 10px' }}>
+                        <Select aria-label="Subject kind" size="sm" className="max-w-[160px]" selectedKeys={[subjectKind]}
+                                onChange={e => setSubjectKind(e.target.value || 'ServiceAccount')}>
+                            {['ServiceAccount', 'User', 'Group'].map(kind => <SelectItem key={kind} value={kind}>{kind}</SelectItem>)}
+                        </Select>
+                        {subjectKind === 'ServiceAccount' && (
+                            <Input aria-label="Namespace" size="sm" placeholder="Namespace" value={subjectNamespace} onValueChange={setSubjectNamespace} />
+                        )}
+                        <Input aria-label="Name" size="sm" placeholder="Name" value={subjectName} onValueChange={setSubjectName} />
+                        <Button size="sm" color="secondary" onClick={handleLeastPrivilegeClick} isDisabled={!subjectName}>Least privilege</Button>
+                    </div>
+                    {leastPrivilegeError && <p className="text-tiny text-danger px-3">{leastPrivilegeError}</p>}
                     <CardBody style={{ height: '100%', padding: 10 }}>
                         <Editor
                             height="100%"
                             defaultLanguage="yaml"
-                            defaultValue={``}
+                            value={yamlContent}
                             className="p-1 rounded-b-lg"
                             theme={editorTheme}
                             onChange={handleEditorChange}
@@ -75,9 +111,20 @@ export default function WhatIfPage() {
                             <DisjointGraph data={graphData} disable={true} />
                         )}
                     </CardBody>
+                    {graphData?.permissions && (
+                        <CardBody style={{ maxHeight: '40%', overflowY: 'auto', padding: 10 }}>
+                            <h3 className="mb-2">Object changes</h3>
+                            <ObjectChanges changes={[...(graphData.bindings ?? []), ...(graphData.roles ?? [])]} />
+                            <h3 className="mb-2">Permission changes</h3>
+                            <PermissionChanges changes={graphData.permissions} />
+                        </CardBody>
+                    )}
                 </Card>
             </div>
-            <Button onClick={handleGenerateClick} color="primary" variant="shadow" style={{ marginTop: '10px', marginBottom: '10px', width: '100%' }}>Generate</Button>
+            <div style={{ display: 'flex', gap: '10px', marginTop: '10px', marginBottom: '10px' }}>
+                <Button onClick={() => handleGenerateClick('apply')} color="primary" variant="shadow" style={{ flex: 1 }}>Generate</Button>
+                <Button onClick={() => handleGenerateClick('delete')} color="danger" variant="shadow" style={{ flex: 1 }}>Simulate delete</Button>
+            </div>
         </section>
     );
 }
@@ -5,11 +5,35 @@ import { useTheme } from 'next-themes';
 import debounce from 'lodash.debounce';
 import axios from "axios";
 import { Select, SelectItem, Button } from '@nextui-org/react';
+import RoleDetails, { RoleData } from './role-details';
+
+type Finding = {
+    checkId: string;
+    title: string;
+    severity: "critical" | "high" | "medium" | "low";
+};
+
+const severityColors: Record<Finding["severity"], string> = {
+    critical: '#d00',
+    high: 'red',
+    medium: 'gold',
+    low: 'lightblue',
+};
+
+// riskColor returns the colour of the most severe finding of a node
+const riskColor = (findings?: Finding[]) => {
+    const severity = (["critical", "high", "medium", "low"] as const).find(s => findings?.some(f => f.severity === s));
+    return severity ? severityColors[severity] : 'none';
+};
 
 interface Node extends d3.SimulationNodeDatum {
     id: string;
     kind?: string;
     label: string;
+    findings?: Finding[];
+    role?: RoleData;
+    clusterAdmin?: boolean;
+    usage?: BindingUsage;
     x?: number;
     y?: number;
 }
@@ -17,11 +41,13 @@ interface Node extends d3.SimulationNodeDatum {
 interface Link extends d3.SimulationLinkDatum<Node> {
     source: string | Node;
     target: string | Node;
+    unused?: boolean;
 }
 
 type Subject = {
     kind: string;
     apiGroup: string;
+    namespace?: string;
     name: string;
 };
 
@@ -31,12 +57,42 @@ type RoleRef = {
     name: string;
 };
 
+type RoleComponent = {
+    id: string;
+    name: string;
+    rules: unknown[];
+};
+
+type Workload = {
+    id: string;
+    kind: string;
+    name: string;
+    namespace: string;
+    serviceAccountId: string;
+    automountToken: boolean;
+    clusterAdmin: boolean;
+};
+
+// BindingUsage summarizes the requests of the audit logs a binding authorized
+type BindingUsage = {
+    id: string;
+    rules: number;
+    unusedRules: number;
+    requests: number;
+    lastUsed?: string;
+};
+
 type BindingData = {
-    id: number;
+    id: string;
     name: string;
+    namespace?: string;
     kind: string;
     subjects: Subject[];
     roleRef: RoleRef;
+    subjectIds: string[];
+    roleRefId: string;
+    roleRefComponents?: RoleComponent[];
+    findings?: Finding[];
     details?: string;
 };
 
@@ -54,6 +110,13 @@ const Tooltip = ({ node, isDarkMode }: { node: Node; isDarkMode: boolean }) => (
         transform: 'translate(-50%, -100%)',
     }}>
         {node.label}
+        {node.usage && (node.usage.requests === 0
+            ? <div style={{ color: 'gray', fontSize: 'small' }}>never used in audit logs</div>
+            : <div style={{ fontSize: 'small' }}>{node.usage.requests} requests, {node.usage.unusedRules} of {node.usage.rules} rules never used</div>)}
+        {node.clusterAdmin && <div style={{ color: severityColors.critical, fontSize: 'small' }}>runs with cluster-admin rights</div>}
+        {node.findings?.map(f => (
+            <div key={f.checkId} style={{ color: severityColors[f.severity], fontSize: 'small' }}>{f.severity}: {f.title}</div>
+        ))}
     </div>
 );
 
@@ -64,10 +127,14 @@ const DisjointGraph = ({ data, disable }: { data?: { nodes: Node[], links: Link[
     const [allNodes, setAllNodes] = useState<Node[]>([]);
     const [allLinks, setAllLinks] = useState<Link[]>([]);
     const [bindingData, setBindingData] = useState<BindingData[]>([]);
+    const [roles, setRoles] = useState<Map<string, RoleData>>(new Map());
+    const [workloads, setWorkloads] = useState<Workload[]>([]);
+    const [usage, setUsage] = useState<Map<string, BindingUsage>>(new Map());
+    const [selectedRole, setSelectedRole] = useState<RoleData | null>(null);
     const { theme } = useTheme();
     const isDarkMode = theme === 'dark';
 
-    const processGraphData = (data: BindingData[]) => {
+    const processGraphData = (data: BindingData[], roles: Map<string, RoleData>, workloads: Workload[], usage: Map<string, BindingUsage>) => {
         const nodes: Node[] = [];
         const links: Link[] = [];
 
@@ -77,25 +144,50 @@ const DisjointGraph = ({ data, disable }: { data?: { nodes: Node[], links: Link[
                 return;
             }
 
-            nodes.push({ id: binding.name, kind: binding.kind, label: binding.name });
+            const label = (kind: string, name: string, namespace?: string) =>
+                namespace ? `${kind} - ${namespace}/${name}` : `${kind} - ${name}`;
 
-            binding.subjects.forEach(subject => {
+            const bindingUsage = usage.get(binding.id);
+            const unused = bindingUsage?.requests === 0;
+            nodes.push({ id: binding.id, kind: binding.kind, label: label(binding.kind, binding.name, binding.namespace), findings: binding.findings, usage: bindingUsage });
+
+            binding.subjects.forEach((subject, index) => {
                 if (!subject.kind || !subject.apiGroup || !subject.name) {
                     console.error('Invalid subject data:', subject);
                     return;
                 }
-                const subjectId = `${subject.kind}-${subject.name}`;
+                const subjectId = binding.subjectIds[index];
+                const subjectNamespace = subject.kind === 'ServiceAccount' ? subject.namespace ?? binding.namespace : undefined;
                 if (!nodes.find(n => n.id === subjectId)) {
-                    nodes.push({ id: subjectId, label: `${subject.kind} - ${subject.name}` });
+                    nodes.push({ id: subjectId, label: label(subject.kind, subject.name, subjectNamespace) });
                 }
-                links.push({ source: binding.name, target: subjectId });
+                links.push({ source: binding.id, target: subjectId, unused });
             });
 
-            const roleRefId = `${binding.roleRef.kind}-${binding.roleRef.name}`;
+            const roleRefId = binding.roleRefId;
+            const roleRefNamespace = binding.roleRef.kind === 'Role' ? binding.namespace : undefined;
             if (!nodes.find(n => n.id === roleRefId)) {
-                nodes.push({ id: roleRefId, label: `${binding.roleRef.kind} - ${binding.roleRef.name}` });
+                nodes.push({ id: roleRefId, label: label(binding.roleRef.kind, binding.roleRef.name, roleRefNamespace), role: roles.get(roleRefId) });
             }
-            links.push({ source: binding.name, target: roleRefId });
+            links.push({ source: binding.id, target: roleRefId, unused });
+
+            // Aggregated ClusterRoles link to the ClusterRoles their rules come from
+            binding.roleRefComponents?.forEach(component => {
+                if (!nodes.find(n => n.id === component.id)) {
+                    nodes.push({ id: component.id, label: `${label('ClusterRole', component.name)} (${component.rules.length} aggregated rules)`, role: roles.get(component.id) });
+                }
+                if (!links.find(l => l.source === roleRefId && l.target === component.id)) {
+                    links.push({ source: roleRefId, target: component.id });
+                }
+            });
+        });
+
+        // Workloads link to the bound service accounts their pods run as
+        workloads.forEach(workload => {
+            if (!nodes.find(n => n.id === workload.serviceAccountId)) return;
+            const token = workload.automountToken ? '' : ' (token not mounted)';
+            nodes.push({ id: workload.id, kind: 'Workload', label: `${workload.kind} - ${workload.namespace}/${workload.name}${token}`, clusterAdmin: workload.clusterAdmin });
+            links.push({ source: workload.serviceAccountId, target: workload.id });
         });
 
         return { nodes, links };
@@ -154,15 +246,21 @@ const DisjointGraph = ({ data, disable }: { data?: { nodes: Node[], links: Link[
             .enter().append('line')
             .attr('class', 'link')
             .attr('stroke', '#666')
-            .attr('stroke-width', 2);
+            .attr('stroke-width', 2)
+            .attr('stroke-dasharray', d => d.unused ? '4 4' : null);
 
         const node = g.selectAll('.node')
             .data(nodesToRender)
             .enter().append('circle')
             .attr('class', 'node')
             .attr('r', 10)
-            .attr('fill', d => d.kind === 'ClusterRoleBinding' ? 'orange' : d.kind === 'RoleBinding' ? 'green' : 'pink')
+            .attr('fill', d => d.kind === 'ClusterRoleBinding' ? 'orange' : d.kind === 'RoleBinding' ? 'green' : d.kind === 'Workload' ? 'skyblue' : 'pink')
+            .attr('stroke', d => d.clusterAdmin ? severityColors.critical : riskColor(d.findings))
+            .attr('stroke-width', 4)
+            // Bindings that authorized no request of the audit logs are faded
+            .attr('fill-opacity', d => d.usage?.requests === 0 ? 0.35 : 1)
             .call(drag(simulation) as any)
+            .on('click', (_event, d) => setSelectedRole(d.role ?? null))
             .on('mouseover', debounce((_event, d) => setHoveredNode(d), 50))
             .on('mouseout', debounce(() => setHoveredNode(null), 50));
 
@@ -202,6 +300,7 @@ const DisjointGraph = ({ data, disable }: { data?: { nodes: Node[], links: Link[
         const legendData = [
             { label: 'ClusterRoleBinding', color: 'orange' },
             { label: 'RoleBinding', color: 'green' },
+            { label: 'Workload', color: 'skyblue' },
             { label: 'Other', color: 'pink' }
         ];
 
@@ -232,16 +331,28 @@ const DisjointGraph = ({ data, disable }: { data?: { nodes: Node[], links: Link[
     useEffect(() => {
         const fetchData = async () => {
             try {
-                const response = await axios.get('/api/data');
+                const [response, rolesResponse, workloadsResponse, usageResponse] = await Promise.all([
+                    axios.get('/api/data'), axios.get('/api/roles'), axios.get('/api/workloads'),
+                    // Usage is only served when the server was given audit logs
+                    axios.get('/api/usage').catch(() => ({ data: null })),
+                ]);
                 const data: BindingData[] | null = response.data;
                 if (!data) {
                     console.error(new Error('Data is null or undefined'));
                     return;
                 }
-                const { nodes, links } = processGraphData(data);
+                const roleData: RoleData[] = rolesResponse.data ?? [];
+                const roles = new Map(roleData.map(role => [role.id, role]));
+                const workloads: Workload[] = workloadsResponse.data ?? [];
+                const bindingUsage: BindingUsage[] = usageResponse.data?.bindings ?? [];
+                const usage = new Map(bindingUsage.map(u => [u.id, u]));
+                const { nodes, links } = processGraphData(data, roles, workloads, usage);
                 setAllNodes(nodes);
                 setAllLinks(links);
                 setBindingData(data);
+                setRoles(roles);
+                setWorkloads(workloads);
+                setUsage(usage);
                 renderGraph(nodes, links, new Set());
             } catch (error) {
                 console.error('Error fetching data:', error);
@@ -250,6 +361,13 @@ const DisjointGraph = ({ data, disable }: { data?: { nodes: Node[], links: Link[
 
         if (!data) {
             fetchData().finally(() => { console.log('Data fetching completed'); });
+
+            // Redraw the graph when bindings change, the server already coalesces bursts of changes
+            const source = new EventSource('/api/events');
+            source.addEventListener('bindings', () => {
+                fetchData().catch(error => console.error('Error fetching data:', error));
+            });
+            return () => source.close();
         } else {
             renderGraph(data.nodes, data.links, new Set());
         }
@@ -291,8 +409,8 @@ const DisjointGraph = ({ data, disable }: { data?: { nodes: Node[], links: Link[
         const newSelectedNodes = new Set(Array.from(keys) as string[]);
         setSelectedNodes(newSelectedNodes);
 
-        const selectedData = bindingData.filter(binding => newSelectedNodes.has(binding.name));
-        const { nodes, links } = processGraphData(selectedData);
+        const selectedData = bindingData.filter(binding => newSelectedNodes.has(binding.id));
+        const { nodes, links } = processGraphData(selectedData, roles, workloads, usage);
         renderGraph(nodes, links, newSelectedNodes);
     };
 
@@ -326,6 +444,9 @@ const DisjointGraph = ({ data, disable }: { data?: { nodes: Node[], links: Link[
             {hoveredNode && (
                 <Tooltip node={hoveredNode} isDarkMode={isDarkMode}/>
             )}
+            {selectedRole && (
+                <RoleDetails role={selectedRole} onClose={() => setSelectedRole(null)}/>
+            )}
         </div>
     );
 };
@@ -0,0 +1,81 @@
+"use client";
+import { Chip } from "@nextui-org/react";
+
+export type Permission = {
+    apiGroup: string;
+    resource?: string;
+    subresource?: string;
+    resourceName?: string;
+    nonResourceURL?: string;
+    verb: string;
+    namespace?: string;
+};
+
+export type PermissionChange = {
+    subject: { kind: string; name: string; namespace?: string };
+    added?: Permission[];
+    removed?: Permission[];
+};
+
+// formatPermission renders a permission the same way the CLI does, e.g. "get secrets in payments"
+export const formatPermission = (p: Permission) => {
+    if (p.nonResourceURL) return `${p.verb} ${p.nonResourceURL}`;
+
+    let text = `${p.verb} ${p.resource}`;
+    if (p.apiGroup) text += `.${p.apiGroup}`;
+    if (p.subresource) text += `/${p.subresource}`;
+    if (p.resourceName) text += ` ${p.resourceName}`;
+    return text + (p.namespace ? ` in ${p.namespace}` : " cluster-wide");
+};
+
+export type ObjectChange = {
+    type: "added" | "removed" | "modified";
+    kind: string;
+    namespace?: string;
+    name: string;
+};
+
+const changeColors = { added: "success", removed: "danger", modified: "warning" } as const;
+
+// ObjectChanges lists the bindings and roles a what-if change adds, replaces or removes
+export const ObjectChanges = ({ changes }: { changes: ObjectChange[] }) => (
+    <div className="flex flex-col gap-1 mb-3">
+        {changes.map(change => {
+            const name = change.namespace ? `${change.namespace}/${change.name}` : change.name;
+            return (
+                <div key={`${change.kind}/${name}`}>
+                    <Chip color={changeColors[change.type]} variant="flat" size="sm" className="mr-2">{change.type}</Chip>
+                    {change.kind} - {name}
+                </div>
+            );
+        })}
+    </div>
+);
+
+const PermissionChanges = ({ changes }: { changes: PermissionChange[] }) => {
+    if (changes.length === 0) {
+        return <p className="text-small text-default-500">No subject gains or loses permissions.</p>;
+    }
+
+    return (
+        <div className="flex flex-col gap-3">
+            {changes.map(change => {
+                const subject = change.subject;
+                const name = subject.namespace ? `${subject.namespace}/${subject.name}` : subject.name;
+                return (
+                    <div key={`${subject.kind}/${name}`}>
+                        <p className="font-bold">{subject.kind} - {name}</p>
+                        {change.added?.map(p => (
+                            <Chip key={`+${formatPermission(p)}`} color="success" variant="flat" size="sm" className="m-1">+ {formatPermission(p)}</Chip>
+                        ))}
+                        {change.removed?.map(p => (
+                            <Chip key={`-${formatPermission(p)}`} color="danger" variant="flat" size="sm" className="m-1">- {formatPermission(p)}</Chip>
+                        ))}
+                    </div>
+                );
+            })}
+        </div>
+    );
+};
+
+export default PermissionChanges;
@@ -0,0 +1,74 @@
+"use client";
+import { Card, CardBody, CardHeader, Chip, Button } from "@nextui-org/react";
+
+export type PolicyRule = {
+    verbs: string[];
+    apiGroups?: string[];
+    resources?: string[];
+    resourceNames?: string[];
+    nonResourceURLs?: string[];
+};
+
+export type RoleData = {
+    id: string;
+    name: string;
+    namespace?: string;
+    kind: string;
+    labels?: Record<string, string>;
+    annotations?: Record<string, string>;
+    rules: PolicyRule[];
+    components?: { id: string; name: string; rules: PolicyRule[] }[];
+    aggregatedInto?: string[];
+};
+
+// formatRule renders a policy rule the same way the CLI does, e.g. `verbs=get,list apiGroups="" resources=pods`
+export const formatRule = (rule: PolicyRule) => {
+    const parts = [`verbs=${rule.verbs.join(",")}`];
+    if (rule.apiGroups?.length) parts.push(`apiGroups=${rule.apiGroups.map(g => JSON.stringify(g)).join(",")}`);
+    if (rule.resources?.length) parts.push(`resources=${rule.resources.join(",")}`);
+    if (rule.resourceNames?.length) parts.push(`resourceNames=${rule.resourceNames.join(",")}`);
+    if (rule.nonResourceURLs?.length) parts.push(`nonResourceURLs=${rule.nonResourceURLs.join(",")}`);
+    return parts.join(" ");
+};
+
+const Rules = ({ rules }: { rules: PolicyRule[] }) => (
+    <ul className="list-disc ml-5">
+        {rules.map(rule => <li key={formatRule(rule)} className="font-mono text-small">{formatRule(rule)}</li>)}
+    </ul>
+);
+
+// RoleDetails shows what a Role or ClusterRole grants, with the components of aggregated ClusterRoles
+const RoleDetails = ({ role, onClose }: { role: RoleData; onClose: () => void }) => {
+    const name = role.namespace ? `${role.namespace}/${role.name}` : role.name;
+
+    return (
+        <Card className="absolute right-2 top-16 max-w-lg max-h-[70%] z-10">
+            <CardHeader className="flex justify-between">
+                <p className="font-bold">{role.kind} - {name}</p>
+                <Button size="sm" variant="light" onClick={onClose}>Close</Button>
+            </CardHeader>
+            <CardBody className="gap-2 overflow-auto">
+                {role.labels && Object.entries(role.labels).map(([key, value]) => (
+                    <Chip key={key} size="sm" variant="flat" className="mr-1">{key}={value}</Chip>
+                ))}
+                {role.rules.length === 0 ? (
+                    <p className="text-small text-default-500">The role grants nothing.</p>
+                ) : role.components?.length ? (
+                    role.components.map(component => (
+                        <div key={component.id}>
+                            <p className="text-small font-bold">Aggregated from ClusterRole - {component.name}</p>
+                            <Rules rules={component.rules} />
+                        </div>
+                    ))
+                ) : (
+                    <Rules rules={role.rules} />
+                )}
+                {role.aggregatedInto?.length ? (
+                    <p className="text-small text-default-500">Aggregated into {role.aggregatedInto.join(", ")}</p>
+                ) : null}
+            </CardBody>
+        </Card>
+    );
+};
+
+export default RoleDetails;
@@ -17,7 +17,8 @@ import {
     Pagination,
     Selection,
     ChipProps,
-    SortDescriptor
+    SortDescriptor,
+    Tooltip
 } from "@nextui-org/react";
 import { SearchIcon, VerticalDotsIcon, ChevronDownIcon, RefreshIcon, CopyIcon } from "@/components/icons";
 import { Modal, ModalBody, ModalContent } from "@nextui-org/modal";
@@ -39,6 +40,8 @@ const columns = [
     { name: "KIND", uid: "kind" },
     { name: "SUBJECTS", uid: "subjects" },
     { name: "ROLE REF", uid: "role_ref" },
+    { name: "RISK", uid: "riskScore", sortable: true },
+    { name: "USAGE", uid: "usage" },
     { name: "DETAILS", uid: "details" },
 ];
 
@@ -59,17 +62,70 @@ type RoleRef = {
     name: string;
 };
 
+type Finding = {
+    checkId: string;
+    title: string;
+    severity: "critical" | "high" | "medium" | "low";
+    evidence: string;
+};
+
+const severityColorMap: Record<Finding["severity"], ChipProps["color"]> = {
+    critical: "danger",
+    high: "danger",
+    medium: "warning",
+    low: "primary",
+};
+
+// BindingUsage summarizes the requests of the audit logs a binding authorized
+type BindingUsage = {
+    id: string;
+    rules: number;
+    unusedRules: number;
+    requests: number;
+    lastUsed?: string;
+};
+
 type BindingData = {
-    id: number;
+    id: string;
+    cluster?: string;
     name: string;
+    namespace?: string;
     kind: string;
     subjects: Subject[];
     roleRef: RoleRef;
+    subjectIds?: string[];
+    roleRefId?: string;
     raw?: string;
+    findings?: Finding[];
+    riskScore: number;
+};
+
+type BindingEvent = {
+    type: "ADDED" | "MODIFIED" | "DELETED";
+    data: BindingData;
 };
 
+// applyEvents applies a batch of binding changes streamed from /api/events to the bindings
+function applyEvents(bindings: BindingData[], events: BindingEvent[]): BindingData[] {
+    const updated = [...bindings];
+
+    events.forEach(event => {
+        const index = updated.findIndex(binding => binding.id === event.data.id);
+        if (event.type === "DELETED") {
+            if (index !== -1) updated.splice(index, 1);
+        } else if (index !== -1) {
+            updated[index] = event.data;
+        } else {
+            updated.push(event.data);
+        }
+    });
+
+    return updated;
+}
+
 export default function MainTable() {
     const [data, setData] = useState<BindingData[]>([]);
+    const [usage, setUsage] = useState<Map<string, BindingUsage>>(new Map());
     const [filterValue, setFilterValue] = React.useState("");
     const [selectedKeys, setSelectedKeys] = React.useState<Selection>(new Set([]));
     const [visibleColumns, setVisibleColumns] = React.useState<Selection>(new Set(columns.map(column => column.uid)));
@@ -88,6 +144,32 @@ export default function MainTable() {
         axios.get('/api/data')
             .then(response => setData(response.data))
             .catch(error => console.error('Error fetching data:', error));
+
+        // Usage is only served when the server was given audit logs
+        axios.get('/api/usage')
+            .then(response => setUsage(new Map((response.data.bindings as BindingUsage[]).map(u => [u.id, u]))))
+            .catch(() => setUsage(new Map()));
+    }, []);
+
+    useEffect(() => {
+        const source = new EventSource('/api/events');
+
+        // Reload the bindings when the stream reconnects, as changes may have been missed meanwhile
+        let connected = false;
+        source.addEventListener('open', () => {
+            if (connected) {
+                axios.get('/api/data')
+                    .then(response => setData(response.data))
+                    .catch(error => console.error('Error fetching data:', error));
+            }
+            connected = true;
+        });
+        source.addEventListener('bindings', event => {
+            const events: BindingEvent[] = JSON.parse((event as MessageEvent).data);
+            setData(current => applyEvents(current, events));
+        });
+
+        return () => source.close();
     }, []);
 
     useEffect(() => {
@@ -177,6 +259,33 @@ export default function MainTable() {
                         <p>{data.roleRef?.kind} - {data.roleRef?.apiGroup} - {data.roleRef?.name}</p>
                     </div>
                 );
+            case "riskScore":
+                return (
+                    <div className="flex flex-wrap gap-1">
+                        {data.findings?.map(finding => (
+                            <Tooltip key={finding.checkId} content={finding.evidence}>
+                                <Chip color={severityColorMap[finding.severity]} size="sm" variant="flat">
+                                    {finding.title}
+                                </Chip>
+                            </Tooltip>
+                        ))}
+                    </div>
+                );
+            case "usage": {
+                const bindingUsage = usage.get(data.id);
+                if (!bindingUsage) return "-";
+                if (bindingUsage.requests === 0) {
+                    return <Chip size="sm" variant="flat">never used</Chip>;
+                }
+                return (
+                    <div className="flex flex-col">
+                        <p className="text-small">{bindingUsage.requests} requests</p>
+                        {bindingUsage.unusedRules > 0 && (
+                            <p className="text-tiny text-default-400">{bindingUsage.unusedRules} of {bindingUsage.rules} rules never used</p>
+                        )}
+                    </div>
+                );
+            }
             case "details":
                 return (
                     <div className="relative flex justify-center items-center gap-2">
@@ -200,7 +309,7 @@ export default function MainTable() {
             default:
                 return typeof cellValue === 'string' || typeof cellValue === 'number' ? cellValue : JSON.stringify(cellValue);
         }
-    }, []);
+    }, [usage]);
 
     const onNextPage = React.useCallback(() => {
         if (page < pages) {